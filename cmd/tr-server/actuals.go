@@ -0,0 +1,345 @@
+package main
+
+// This includes the code that keeps the actuals log, a record of
+// when each task was really started and stopped, kept apart from
+// the schedule so that it isn't lost when the plan changes. The
+// log is written to the state directory for each day, and entries
+// are added whenever the current task changes, as well as through
+// the start, stop and skip endpoints.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+const actualsDirName = "actuals"
+
+// actualsFile returns the name of the actuals log for the given day.
+func (s *Server) actualsFile(day time.Time) string {
+	return filepath.Join(s.StateDir, actualsDirName, day.Format(time.DateOnly)+".json")
+}
+
+// loadActuals returns the actuals log for the given day.
+// The caller must hold s.mu.
+func (s *Server) loadActuals(day time.Time) (tr.Actuals, error) {
+	actuals := tr.Actuals{}
+	if s.StateDir == "" {
+		return actuals, nil
+	}
+	payload, err := os.ReadFile(s.actualsFile(day))
+	if errors.Is(err, os.ErrNotExist) {
+		return actuals, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("loadActuals: %w", err)
+	}
+	err = json.Unmarshal(payload, &actuals)
+	if err != nil {
+		return nil, fmt.Errorf("loadActuals: %w", err)
+	}
+	return actuals, nil
+}
+
+// saveActuals writes the actuals log for the given day to
+// the state directory. The caller must hold s.mu.
+func (s *Server) saveActuals(day time.Time, actuals tr.Actuals) error {
+	if s.StateDir == "" {
+		return errors.New("saveActuals: no state directory")
+	}
+	err := os.MkdirAll(filepath.Join(s.StateDir, actualsDirName), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("saveActuals: %w", err)
+	}
+	payload, err := json.MarshalIndent(actuals, "", "\t")
+	if err != nil {
+		return fmt.Errorf("saveActuals: %w", err)
+	}
+	err = tr.WriteFileAtomic(s.actualsFile(day), payload, 0644)
+	if err != nil {
+		return fmt.Errorf("saveActuals: %w", err)
+	}
+	return nil
+}
+
+// updateActuals applies f to the actuals log for the given
+// day and saves it. The caller must hold s.mu.
+func (s *Server) updateActuals(day time.Time, f func(*tr.Actuals) error) error {
+	actuals, err := s.loadActuals(day)
+	if err != nil {
+		return fmt.Errorf("updateActuals: %w", err)
+	}
+	err = f(&actuals)
+	if err != nil {
+		return fmt.Errorf("updateActuals: %w", err)
+	}
+	return s.saveActuals(day, actuals)
+}
+
+// actualsDay returns the day whose actuals log is being kept: the
+// live schedule's day, so that entries made by hand and those made
+// as the current task changes go to the same log until the schedule
+// rolls over, or today if there is no schedule. The caller must
+// hold s.mu.
+func (s *Server) actualsDay() time.Time {
+	if s.Schedule != nil {
+		return s.Schedule.Day
+	}
+	return s.today()
+}
+
+// trackCurrent records in the actuals log that the schedule's
+// current task has started, if it isn't the task it last recorded.
+// A break stops the task that was running. Failures are logged,
+// since they shouldn't keep the schedule from changing. The caller
+// must hold s.mu.
+func (s *Server) trackCurrent() {
+	if s.StateDir == "" || s.Schedule == nil {
+		return
+	}
+	current := s.Schedule.CurrentTask
+	id := ""
+	if current != nil && !current.IsBreak() {
+		id = current.ID
+	}
+	if id == s.tracked {
+		return
+	}
+	s.tracked = id
+	err := s.updateActuals(s.actualsDay(), func(a *tr.Actuals) error {
+		if id == "" {
+			if a.Running() != nil {
+				return a.Stop(s.now())
+			}
+			return nil
+		}
+		a.Start(*current, s.now())
+		return nil
+	})
+	if err != nil {
+		log.Printf("trackCurrent: %s", err.Error())
+	}
+}
+
+// stopTracking stops the task running in the actuals log for the
+// live schedule's day, no later than the end of the day. It is used
+// when the day is over. The caller must hold s.mu.
+func (s *Server) stopTracking() {
+	s.tracked = ""
+	if s.StateDir == "" || s.Schedule == nil {
+		return
+	}
+	at := s.now()
+	if _, end := s.Schedule.Bounds(); end.Before(at) {
+		at = end
+	}
+	err := s.updateActuals(s.actualsDay(), func(a *tr.Actuals) error {
+		if a.Running() != nil {
+			return a.Stop(at)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("stopTracking: %s", err.Error())
+	}
+}
+
+// ActualsModel is the JSON representation of the actuals
+// log for a day, and how it compares with that day's plan.
+type ActualsModel struct {
+	Date       string        `json:"date"`
+	Actuals    tr.Actuals    `json:"actuals"`
+	Comparison tr.Comparison `json:"comparison"`
+}
+
+// plannedTasks returns the tasks planned for the given day: the live
+// schedule's if it is for that day, or else the archived schedule's.
+// The caller must hold s.mu.
+func (s *Server) plannedTasks(day time.Time) (tr.TaskList, error) {
+	if s.Schedule != nil && s.Schedule.Day.Equal(day) {
+		return s.Schedule.Tasks, nil
+	}
+	if s.StateDir == "" {
+		return nil, nil
+	}
+	name := filepath.Join(s.StateDir, archiveDirName, day.Format(time.DateOnly)+".json")
+	sched, err := tr.ReadFromFile(name, day.Location(), s.DayStart)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("plannedTasks: %w", err)
+	}
+	return sched.Tasks, nil
+}
+
+// respondWithActuals responds with the actuals log for the given
+// day compared with its plan. The caller must hold s.mu.
+func (s *Server) respondWithActuals(w http.ResponseWriter, day time.Time) {
+	actuals, err := s.loadActuals(day)
+	if err != nil {
+		log.Printf("respondWithActuals: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	tl, err := s.plannedTasks(day)
+	if err != nil {
+		log.Printf("respondWithActuals: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	model := ActualsModel{
+		Date:       day.Format(time.DateOnly),
+		Actuals:    actuals,
+		Comparison: actuals.Compare(tl, s.now()),
+	}
+	err = tr.SendJson(model, w)
+	if err != nil {
+		log.Printf("respondWithActuals: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetActuals responds with the actuals log for the day given by the
+// query date, or the current day if there is none, compared with
+// its plan.
+func (s *Server) GetActuals(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := s.actualsDay()
+	if q := r.URL.Query().Get("date"); q != "" {
+		var err error
+		day, err = time.ParseInLocation(time.DateOnly, q, s.now().Location())
+		if err != nil {
+			http.Error(w, "Please give the date formatted as "+time.DateOnly+".", http.StatusBadRequest)
+			return
+		}
+	}
+	s.respondWithActuals(w, day)
+}
+
+// ActualRequest names the task that a request to start or skip
+// a task is for. ID is the ID of a task in the schedule. Without
+// an ID, a description gives a task that isn't in the schedule,
+// and without either, the request is for the current task.
+type ActualRequest struct {
+	ID          string `json:"ID"`
+	Description string `json:"Description"`
+	Tag         string `json:"Tag"`
+}
+
+// requestedTask returns the task that the request body names.
+// The caller must hold s.mu.
+func (s *Server) requestedTask(r *http.Request) (tr.Task, error) {
+	var req ActualRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return tr.Task{}, fmt.Errorf("requestedTask: %w", err)
+	}
+	if req.ID == "" && req.Description != "" {
+		return tr.Task{Description: req.Description, Tag: req.Tag}, nil
+	}
+	if s.Schedule == nil {
+		return tr.Task{}, errNoSuchTask
+	}
+	task := s.Schedule.CurrentTask
+	if req.ID != "" {
+		task, _ = s.Schedule.Tasks.GetTaskByID(req.ID)
+	}
+	if task == nil || task.IsBreak() {
+		return tr.Task{}, errNoSuchTask
+	}
+	return *task, nil
+}
+
+// errNoSuchTask is returned by requestedTask when the
+// request names no task that is in the schedule.
+var errNoSuchTask = errors.New("no such task in the schedule")
+
+// writeRequestedTaskError responds with the reason requestedTask failed.
+func writeRequestedTaskError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNoSuchTask) {
+		http.Error(w, "No such task in the schedule.", http.StatusNotFound)
+	} else {
+		log.Printf("writeRequestedTaskError: %s", err.Error())
+		http.Error(w, "Invalid HTTP Body", http.StatusBadRequest)
+	}
+}
+
+// StartActual records in the current day's actuals log that
+// the task named in the request body has started, and that the
+// task that was running has stopped. It responds with the log.
+func (s *Server) StartActual(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.requestedTask(r)
+	if err != nil {
+		writeRequestedTaskError(w, err)
+		return
+	}
+	day := s.actualsDay()
+	err = s.updateActuals(day, func(a *tr.Actuals) error {
+		a.Start(task, s.now())
+		return nil
+	})
+	if err != nil {
+		log.Printf("StartActual: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.respondWithActuals(w, day)
+}
+
+// StopActual records in the current day's actuals log that
+// the running task has stopped. It responds with the log.
+func (s *Server) StopActual(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := s.actualsDay()
+	err := s.updateActuals(day, func(a *tr.Actuals) error {
+		return a.Stop(s.now())
+	})
+	if errors.As(err, &tr.TaskNotFoundError{}) {
+		http.Error(w, "No task is running.", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("StopActual: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.respondWithActuals(w, day)
+}
+
+// SkipActual records in the current day's actuals log that
+// the task named in the request body was skipped. It responds
+// with the log.
+func (s *Server) SkipActual(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task, err := s.requestedTask(r)
+	if err != nil {
+		writeRequestedTaskError(w, err)
+		return
+	}
+	day := s.actualsDay()
+	err = s.updateActuals(day, func(a *tr.Actuals) error {
+		a.Skip(task, s.now())
+		return nil
+	})
+	if err != nil {
+		log.Printf("SkipActual: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.respondWithActuals(w, day)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActuals(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	now := c.Now()
+
+	w := serve(t, router, "POST", "/v1/actuals/stop", "", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d with nothing running, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Task 1 is started when it's seen to be current, and stopped by the break after it
+	s.CheckCurrentTask()
+	c.Set(now.Add(35 * time.Minute))
+	s.CheckCurrentTask()
+	w = serve(t, router, "POST", "/v1/actuals/start", "", `{"Description": "Email"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	c.Set(now.Add(45 * time.Minute))
+	s.CheckCurrentTask()
+	w = serve(t, router, "POST", "/v1/actuals/skip", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = serve(t, router, "POST", "/v1/actuals/skip", "", `{"ID": "nope"}`)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d for an unknown task, got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = serve(t, router, "GET", "/v1/actuals", "", "")
+	var model ActualsModel
+	err := json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var desc []string
+	for _, a := range model.Actuals {
+		desc = append(desc, a.Description)
+	}
+	if strings.Join(desc, ",") != "Task 1,Email,Task 2,Task 2" || !model.Actuals[3].Skipped {
+		t.Fatalf("Unexpected actuals: %+v", model.Actuals)
+	}
+	tasks := model.Comparison.Tasks
+	if len(tasks) != 2 || time.Duration(tasks[0].Spent) != 35*time.Minute || !tasks[1].Skipped {
+		t.Fatalf("Expected Task 1 to take 35 minutes and Task 2 to be skipped, got %+v", tasks)
+	}
+	if len(model.Comparison.Unplanned) != 1 || model.Comparison.Unplanned[0].Description != "Email" {
+		t.Fatalf("Expected Email to be unplanned, got %+v", model.Comparison.Unplanned)
+	}
+
+	if _, err := os.Stat(filepath.Join(s.StateDir, actualsDirName, "2024-03-10.json")); err != nil {
+		t.Fatalf("Expected the actuals log to be saved: %s", err.Error())
+	}
+	w = serve(t, router, "GET", "/v1/actuals?date=yesterday", "", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an invalid date, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	// Until the schedule rolls over, entries go to the log for its day
+	c.Set(time.Date(2024, time.March, 11, 0, 30, 0, 0, time.Local))
+	w = serve(t, router, "POST", "/v1/actuals/start", "", `{"Description": "Late"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	model = ActualsModel{}
+	err = json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if model.Date != "2024-03-10" || model.Actuals.Running() == nil || model.Actuals.Running().Description != "Late" {
+		t.Fatalf("Expected Late to be running in the log for 2024-03-10, got %+v", model)
+	}
+	if _, err := os.Stat(filepath.Join(s.StateDir, actualsDirName, "2024-03-11.json")); !os.IsNotExist(err) {
+		t.Fatalf("Expected no actuals log for the next day")
+	}
+}
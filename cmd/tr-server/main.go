@@ -1,15 +1,16 @@
 package main
 
 import (
+	"errors"
 	"flag"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/gorilla/mux"
+	tr "github.com/dethancosta/timeruler/internal"
 )
 
 const DefaultPort = 6576
@@ -20,24 +21,59 @@ var (
 
 func main() {
 
-	s := Server{
+	s := &Server{
 		Owner: "",
 		Addr:  "",
-		Ntfy:  "",
 	}
 
 	// TODO ensure port value is valid
 	var port int
 	var standalone bool
 	var NtfyId string
+	var ntfyURL string
+	var webhookURL string
+	var hookCommand string
+	var logNotify bool
+	var stateDir string
+	var warnings tr.WarningPolicy
 	flag.IntVar(&port, "p", DefaultPort, "The port that the server will run on")
 	flag.BoolVar(&standalone, "sa", false, "Whether or not the server is run locally (StandAlone)")
-	flag.StringVar(&NtfyId, "n", "", "The ntfy.sh address to send push notifications to.")
+	flag.StringVar(&NtfyId, "n", "", "The ntfy topic to send push notifications to.")
+	flag.StringVar(&ntfyURL, "ntfy-url", DefaultNtfyURL, "The ntfy server to use, for self-hosted instances.")
+	flag.StringVar(&webhookURL, "webhook", "", "A URL to POST notifications to as JSON.")
+	flag.StringVar(&hookCommand, "hook", "", "A shell command to run for each notification.")
+	flag.BoolVar(&logNotify, "log-notify", false, "Whether or not notifications are written to the log.")
+	flag.Func("warn", "Comma-separated times before a task ends to send a warning, e.g. 10m,2m.", func(value string) error {
+		leads, err := tr.ParseLeadTimes(value)
+		warnings.Default = leads
+		return err
+	})
+	flag.Func("warn-tag", "Warning times for tasks with a given tag, e.g. meeting=15m,5m. May be repeated.", func(value string) error {
+		tag, value, ok := strings.Cut(value, "=")
+		if !ok {
+			return errors.New("expected tag=durations")
+		}
+		leads, err := tr.ParseLeadTimes(value)
+		if err != nil {
+			return err
+		}
+		if warnings.ByTag == nil {
+			warnings.ByTag = make(map[string][]time.Duration)
+		}
+		warnings.ByTag[strings.TrimSpace(tag)] = leads
+		return nil
+	})
+	flag.Func("day-start", "The time each day begins, e.g. 04:00. The day's schedule is archived then.", func(value string) error {
+		dayStart, err := tr.ParseDayStart(value)
+		s.DayStart = dayStart
+		return err
+	})
+	flag.StringVar(&s.Template, "template", "", "A stored template, or a csv, iCalendar or JSON file, to build each day's schedule from if no plan is staged.")
+	flag.StringVar(&stateDir, "d", "", "The directory the schedule is saved to (defaults to the config directory).")
 	flag.Parse()
 	portStr := strconv.Itoa(port)
-	s.Ntfy = NtfyId
-	log.Printf("ntfyid: %s", NtfyId) //TODO delete
-	log.Printf("s.ntfy: %s", s.Ntfy) //TODO delete
+	s.Notifier = NewNotifier(NtfyId, ntfyURL, webhookURL, hookCommand, logNotify)
+	s.Warnings = warnings
 	log.Printf("standalone: %t", standalone) //TODO delete
 
 	if standalone {
@@ -48,48 +84,27 @@ func main() {
 		//log.SetOutput(io.Discard)
 	}
 
-	router := mux.NewRouter()
-	// TODO update API to use
-	// - GET /schedule instead of /get, POST /schedule instead of /build, PUT /schedule instead of /update
-	// - GET /current instead of /current, POST /current instead of /change_current
-	router.Handle("/get", http.HandlerFunc(s.GetSchedule))
-	router.Handle("/build", http.HandlerFunc(s.BuildSchedule))
-	router.Handle("/current", http.HandlerFunc(s.GetCurrentTask))
-	router.Handle("/change_current", http.HandlerFunc(s.ChangeCurrentTask))
-	router.Handle("/update", http.HandlerFunc(s.UpdateTasks))
-
-	ticker := time.NewTicker(time.Second * 30)
-
-	go func() {
-		// TODO refactor
-		for _ = range ticker.C {
-			if s.Schedule == nil {
-				continue
-			}
-			current := s.Schedule.CurrentTask
-			err := s.Schedule.UpdateCurrentTask()
-			if err != nil {
-				fmt.Println(err)
-			}
-			newCurrent := s.Schedule.CurrentTask
-			if newCurrent != nil && current != newCurrent {
-				err := s.NtfyNewCurrent(
-					NtfyId,
-					TaskModel{
-						newCurrent.Description,
-						newCurrent.Tag,
-						newCurrent.EndTime.Format(time.TimeOnly),
-					},
-				)
-				if err != nil {
-					fmt.Println(err)
-				}
-			}
+	if stateDir == "" {
+		var err error
+		stateDir, err = DefaultStateDir()
+		if err != nil {
+			panic(err)
 		}
-	}()
+	}
+	s.StateDir = stateDir
+	err := s.LoadSchedule()
+	if err != nil {
+		log.Printf("Could not restore saved schedule: %s", err.Error())
+	}
+	s.RollOver()
+
+	s.Scheduler = NewScheduler(s)
+	s.Scheduler.Subscribe(s.OnTransition)
+	s.Scheduler.SubscribeWarnings(s.OnWarning)
+	go s.Scheduler.Run(make(chan struct{}))
 
 	log.Printf("Running on %s\n", portStr)
-	err := http.ListenAndServe(Address+":"+portStr, router)
+	err = http.ListenAndServe(Address+":"+portStr, s.Routes())
 	if err != nil {
 		log.Println(err.Error())
 		os.Exit(1)
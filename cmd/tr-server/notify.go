@@ -0,0 +1,300 @@
+package main
+
+// This includes the code used to tell the user about changes
+// to their schedule. Handlers and the scheduler emit Events,
+// and the server's Notifier decides where they are sent.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+const (
+	DefaultNtfyURL = "https://ntfy.sh"
+
+	EventCurrentChanged = "current_changed"
+	EventTaskEnding     = "task_ending"
+)
+
+// Event is something that happened to the schedule
+// that the user should be told about.
+type Event struct {
+	Kind string     `json:"Kind"`
+	Task TaskModel  `json:"Task"`
+	Next *TaskModel `json:"Next,omitempty"` // The task after Task, for warnings
+	Left string     `json:"Left,omitempty"` // Time left in Task, for warnings
+	At   time.Time  `json:"At"`
+}
+
+// Title returns a short summary of the event.
+func (e Event) Title() string {
+	if e.Kind == EventTaskEnding {
+		return e.Task.Description + " ends in " + e.Left
+	}
+	return e.Task.Description
+}
+
+// Message returns the body of a notification for the event.
+func (e Event) Message() string {
+	if e.Kind == EventTaskEnding {
+		if e.Next == nil {
+			return "Nothing scheduled next"
+		}
+		return "Next: " + e.Next.Description + " until " + e.Next.Until
+	}
+	return "Until " + e.Task.Until
+}
+
+// Notifier sends events to the user.
+type Notifier interface {
+	Notify(e Event) error
+}
+
+// NtfyNotifier publishes events to a topic on an ntfy server,
+// which may be ntfy.sh or a self-hosted one.
+type NtfyNotifier struct {
+	BaseURL string // e.g. https://ntfy.sh
+	Topic   string
+	Client  *http.Client
+}
+
+func (n NtfyNotifier) Notify(e Event) error {
+	req, err := http.NewRequest("POST", strings.TrimSuffix(n.BaseURL, "/")+"/"+n.Topic,
+		strings.NewReader(e.Message()))
+	if err != nil {
+		return fmt.Errorf("NtfyNotifier: %w", err)
+	}
+	req.Header.Set("Title", e.Title())
+	req.Header.Set("Tags", "hourglass")
+
+	return send(n.Client, req)
+}
+
+// WebhookNotifier posts each event as JSON to a URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (n WebhookNotifier) Notify(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("WebhookNotifier: %w", err)
+	}
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("WebhookNotifier: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return send(n.Client, req)
+}
+
+// send performs the request and returns an error if it
+// fails or gets a response other than a 2xx.
+func send(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return nil
+}
+
+// CommandNotifier runs a shell command for each event. The
+// event is passed in the TR_EVENT, TR_DESCRIPTION, TR_TAG,
+// TR_UNTIL, TR_TITLE, and TR_MESSAGE environment variables.
+type CommandNotifier struct {
+	Command string
+	Timeout time.Duration // How long the command may run; 30 seconds if zero
+}
+
+func (n CommandNotifier) Notify(e Event) error {
+	timeout := n.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Command)
+	cmd.Env = append(os.Environ(),
+		"TR_EVENT="+e.Kind,
+		"TR_DESCRIPTION="+e.Task.Description,
+		"TR_TAG="+e.Task.Tag,
+		"TR_UNTIL="+e.Task.Until,
+		"TR_TITLE="+e.Title(),
+		"TR_MESSAGE="+e.Message(),
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("CommandNotifier: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// LogNotifier writes each event to a logger, or to
+// the standard logger if none is given.
+type LogNotifier struct {
+	Logger *log.Logger
+}
+
+func (n LogNotifier) Notify(e Event) error {
+	logger := n.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Printf("%s: %s (%s)", e.Kind, e.Title(), e.Message())
+	return nil
+}
+
+// MultiNotifier sends each event to every one of its
+// notifiers, even if some of them fail.
+type MultiNotifier []Notifier
+
+func (m MultiNotifier) Notify(e Event) error {
+	var errs []error
+	for _, n := range m {
+		err := n.Notify(e)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewNotifier returns a Notifier that sends events to each of the
+// given destinations that is set, or nil if none of them are.
+func NewNotifier(ntfyTopic, ntfyURL, webhookURL, hookCommand string, logNotify bool) Notifier {
+	var m MultiNotifier
+	if ntfyTopic != "" {
+		m = append(m, NtfyNotifier{BaseURL: ntfyURL, Topic: ntfyTopic})
+	}
+	if webhookURL != "" {
+		m = append(m, WebhookNotifier{URL: webhookURL})
+	}
+	if hookCommand != "" {
+		m = append(m, CommandNotifier{Command: hookCommand})
+	}
+	if logNotify {
+		m = append(m, LogNotifier{})
+	}
+
+	switch len(m) {
+	case 0:
+		return nil
+	case 1:
+		return m[0]
+	default:
+		return m
+	}
+}
+
+// notifyQueueSize is how many events may be waiting to be
+// sent to a notifier before any more are dropped.
+const notifyQueueSize = 64
+
+// Emit queues the event to be sent to each of the server's
+// notifiers. Every notifier has a goroutine of its own that sends
+// it events in the order they were emitted, so neither callers
+// holding the lock nor the other notifiers are kept waiting on a
+// slow one. If a notifier falls so far behind that its queue is
+// full, the event is dropped for it.
+func (s *Server) Emit(e Event) {
+	if s.Notifier == nil {
+		return
+	}
+	s.startNotifiers.Do(func() {
+		notifiers := []Notifier{s.Notifier}
+		if m, ok := s.Notifier.(MultiNotifier); ok {
+			notifiers = m
+		}
+		for _, n := range notifiers {
+			s.queues = append(s.queues, startNotifier(n))
+		}
+	})
+	for _, q := range s.queues {
+		select {
+		case q <- e:
+		default:
+			log.Printf("Emit: dropped %s event for a notifier that is behind", e.Kind)
+		}
+	}
+}
+
+// startNotifier starts a goroutine that sends the events
+// queued on the returned channel to n, one at a time.
+func startNotifier(n Notifier) chan<- Event {
+	events := make(chan Event, notifyQueueSize)
+	go func() {
+		for e := range events {
+			err := n.Notify(e)
+			if err != nil {
+				log.Printf("Emit: %s", err.Error())
+			}
+		}
+	}()
+	return events
+}
+
+// EmitCurrent emits an event for a change of the current task.
+func (s *Server) EmitCurrent(task TaskModel) {
+	s.Emit(Event{
+		Kind: EventCurrentChanged,
+		Task: task,
+		At:   s.now(),
+	})
+}
+
+// OnWarning is subscribed to the scheduler so that the
+// user is told when a task is about to end.
+func (s *Server) OnWarning(w tr.Warning) {
+	e := Event{
+		Kind: EventTaskEnding,
+		Task: NewTaskModel(&w.Task),
+		Left: formatLead(w.Lead),
+		At:   w.At,
+	}
+	if w.Next != nil {
+		next := NewTaskModel(w.Next)
+		e.Next = &next
+	}
+	s.Emit(e)
+}
+
+// formatLead formats a lead time for people, e.g. "10 minutes".
+func formatLead(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes == 1 {
+		return "1 minute"
+	}
+	if minutes < 1 {
+		return d.String()
+	}
+	return fmt.Sprintf("%d minutes", minutes)
+}
+
+// OnTransition is subscribed to the scheduler so that
+// the user is told when a new task starts.
+func (s *Server) OnTransition(e TransitionEvent) {
+	if e.Current != nil {
+		s.EmitCurrent(NewTaskModel(e.Current))
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testEvent = Event{
+	Kind: EventCurrentChanged,
+	Task: TaskModel{Description: "Write report", Tag: "work", Until: "14:30:00"},
+	At:   time.Date(2024, time.March, 10, 13, 0, 0, 0, time.Local),
+}
+
+func TestNtfyNotifier(t *testing.T) {
+	var path, title, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		title = r.Header.Get("Title")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer ts.Close()
+
+	err := NtfyNotifier{BaseURL: ts.URL + "/", Topic: "my-topic"}.Notify(testEvent)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if path != "/my-topic" || title != "Write report" || body != "Until 14:30:00" {
+		t.Fatalf("Unexpected ntfy request: path %q, title %q, body %q", path, title, body)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got Event
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	err := WebhookNotifier{URL: ts.URL}.Notify(testEvent)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if got.Kind != testEvent.Kind || got.Task != testEvent.Task || !got.At.Equal(testEvent.At) {
+		t.Fatalf("Expected: %+v, Got: %+v", testEvent, got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	err = WebhookNotifier{URL: failing.URL}.Notify(testEvent)
+	if err == nil {
+		t.Fatalf("Expected error when the webhook responds with an error status")
+	}
+}
+
+func TestCommandNotifier(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	n := CommandNotifier{Command: `printf '%s|%s|%s' "$TR_EVENT" "$TR_DESCRIPTION" "$TR_UNTIL" > ` + out}
+	err := n.Notify(testEvent)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if string(b) != "current_changed|Write report|14:30:00" {
+		t.Fatalf("Unexpected command output: %q", string(b))
+	}
+
+	err = CommandNotifier{Command: "echo oops; exit 3"}.Notify(testEvent)
+	if err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Fatalf("Expected error with the command's output, got: %v", err)
+	}
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) Notify(e Event) error {
+	return errors.New("failed")
+}
+
+func TestMultiNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	m := MultiNotifier{failingNotifier{}, LogNotifier{Logger: log.New(&buf, "", 0)}}
+	err := m.Notify(testEvent)
+	if err == nil {
+		t.Fatalf("Expected error from failing notifier")
+	}
+	if !strings.Contains(buf.String(), "Write report") {
+		t.Fatalf("Expected remaining notifiers to run after a failure, got log: %q", buf.String())
+	}
+
+	if NewNotifier("", DefaultNtfyURL, "", "", false) != nil {
+		t.Fatalf("Expected no notifier when none are configured")
+	}
+	if _, ok := NewNotifier("topic", DefaultNtfyURL, "", "", true).(MultiNotifier); !ok {
+		t.Fatalf("Expected a MultiNotifier when several are configured")
+	}
+}
+
+// blockingNotifier sends each event it is given on events,
+// which blocks until the test receives it.
+type blockingNotifier struct {
+	events chan Event
+}
+
+func (n blockingNotifier) Notify(e Event) error {
+	n.events <- e
+	return nil
+}
+
+func TestEmit(t *testing.T) {
+	slow := blockingNotifier{make(chan Event)}
+	var buf syncBuffer
+	s := &Server{Notifier: MultiNotifier{slow, LogNotifier{Logger: log.New(&buf, "", 0)}}}
+
+	// Emitting doesn't wait on the notifiers
+	descriptions := []string{"First", "Second", "Third"}
+	done := make(chan struct{})
+	go func() {
+		for _, d := range descriptions {
+			s.Emit(Event{Kind: EventCurrentChanged, Task: TaskModel{Description: d}})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected Emit not to wait on a slow notifier")
+	}
+
+	// The other notifier isn't held up by the slow one
+	deadline := time.Now().Add(time.Second)
+	for strings.Count(buf.String(), "\n") < len(descriptions) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the log notifier to get every event, got log: %q", buf.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Each notifier gets the events in the order they were emitted
+	for _, d := range descriptions {
+		if e := <-slow.events; e.Task.Description != d {
+			t.Fatalf("Expected %s, got %s", d, e.Task.Description)
+		}
+	}
+	if strings.Index(buf.String(), "First") > strings.Index(buf.String(), "Second") ||
+		strings.Index(buf.String(), "Second") > strings.Index(buf.String(), "Third") {
+		t.Fatalf("Expected the events to be logged in order, got log: %q", buf.String())
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWarningEvent(t *testing.T) {
+	e := Event{
+		Kind: EventTaskEnding,
+		Task: TaskModel{Description: "Write report", Tag: "work", Until: "14:30:00"},
+		Next: &TaskModel{Description: "Standup", Tag: "meeting", Until: "14:45:00"},
+		Left: formatLead(10 * time.Minute),
+	}
+	if e.Title() != "Write report ends in 10 minutes" {
+		t.Fatalf("Unexpected title: %q", e.Title())
+	}
+	if e.Message() != "Next: Standup until 14:45:00" {
+		t.Fatalf("Unexpected message: %q", e.Message())
+	}
+	e.Next = nil
+	if e.Message() != "Nothing scheduled next" {
+		t.Fatalf("Unexpected message: %q", e.Message())
+	}
+}
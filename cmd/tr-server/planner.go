@@ -0,0 +1,271 @@
+package main
+
+// This includes the handlers for the backlog, a list of tasks that
+// haven't been given a time yet, and for the planner, which packs
+// the backlog into the breaks in today's schedule. The backlog is
+// kept in the state directory so that it carries over from day to
+// day. A plan can be previewed as often as needed; once committed,
+// the planned tasks become part of the live schedule and leave the
+// backlog.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	tr "github.com/dethancosta/timeruler/internal"
+	"github.com/gorilla/mux"
+)
+
+const backlogFileName = "backlog.json"
+
+// loadBacklog returns the tasks in the backlog.
+// The caller must hold s.mu.
+func (s *Server) loadBacklog() ([]tr.BacklogTask, error) {
+	backlog := []tr.BacklogTask{}
+	if s.StateDir == "" {
+		return backlog, nil
+	}
+	payload, err := os.ReadFile(filepath.Join(s.StateDir, backlogFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return backlog, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("loadBacklog: %w", err)
+	}
+	err = json.Unmarshal(payload, &backlog)
+	if err != nil {
+		return nil, fmt.Errorf("loadBacklog: %w", err)
+	}
+	return backlog, nil
+}
+
+// saveBacklog writes the backlog to the state
+// directory. The caller must hold s.mu.
+func (s *Server) saveBacklog(backlog []tr.BacklogTask) error {
+	if s.StateDir == "" {
+		return errors.New("saveBacklog: no state directory")
+	}
+	err := os.MkdirAll(s.StateDir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("saveBacklog: %w", err)
+	}
+	payload, err := json.MarshalIndent(backlog, "", "\t")
+	if err != nil {
+		return fmt.Errorf("saveBacklog: %w", err)
+	}
+	err = tr.WriteFileAtomic(filepath.Join(s.StateDir, backlogFileName), payload, 0644)
+	if err != nil {
+		return fmt.Errorf("saveBacklog: %w", err)
+	}
+	return nil
+}
+
+// respondWithBacklog responds with the tasks in the backlog.
+func respondWithBacklog(w http.ResponseWriter, backlog []tr.BacklogTask) {
+	err := tr.SendJson(map[string][]tr.BacklogTask{"backlog": backlog}, w)
+	if err != nil {
+		log.Printf("respondWithBacklog: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetBacklog responds with the tasks in the backlog.
+func (s *Server) GetBacklog(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	backlog, err := s.loadBacklog()
+	if err != nil {
+		log.Printf("GetBacklog: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	respondWithBacklog(w, backlog)
+}
+
+// AddToBacklog adds the JSON array of tasks in the request body
+// to the backlog, and responds with the whole backlog. Each task
+// needs a description and an estimate, such as 45m, and may have
+// a priority, a deadline and an earliest start.
+func (s *Server) AddToBacklog(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	var tasks []tr.BacklogTask
+	err := json.NewDecoder(r.Body).Decode(&tasks)
+	if err != nil {
+		log.Printf("AddToBacklog: %s", err.Error())
+		http.Error(w, "Invalid HTTP Body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for i := range tasks {
+		err = tasks[i].Validate()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Task %d (%q): %s", i, tasks[i].Description, err.Error()), http.StatusBadRequest)
+			return
+		}
+		tasks[i].ID = tr.NewTaskID()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	backlog, err := s.loadBacklog()
+	if err != nil {
+		log.Printf("AddToBacklog: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	backlog = append(backlog, tasks...)
+	err = s.saveBacklog(backlog)
+	if err != nil {
+		log.Printf("AddToBacklog: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	respondWithBacklog(w, backlog)
+}
+
+// DeleteFromBacklog removes a task from the backlog.
+func (s *Server) DeleteFromBacklog(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	id := mux.Vars(r)["id"]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	backlog, err := s.loadBacklog()
+	if err != nil {
+		log.Printf("DeleteFromBacklog: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for i := range backlog {
+		if backlog[i].ID != id {
+			continue
+		}
+		err = s.saveBacklog(append(backlog[:i], backlog[i+1:]...))
+		if err != nil {
+			log.Printf("DeleteFromBacklog: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	http.Error(w, "No task with ID "+id+" in the backlog.", http.StatusNotFound)
+}
+
+// ProposalModel is the JSON representation of a plan for the
+// backlog: the proposed schedule, the backlog tasks that were
+// given a time in it, and those that didn't fit. The token is
+// given to CommitPlan to commit the plan.
+type ProposalModel struct {
+	Token     string              `json:"token"`
+	Schedule  ScheduleModel       `json:"schedule"`
+	Planned   []ScheduleTaskModel `json:"planned"`
+	Unplanned []tr.BacklogTask    `json:"unplanned"`
+}
+
+// NewProposalModel returns the JSON representation of the proposal.
+func NewProposalModel(p tr.Proposal) ProposalModel {
+	model := ProposalModel{
+		Token:     p.Token(),
+		Schedule:  NewScheduleModel(p.Schedule),
+		Planned:   []ScheduleTaskModel{},
+		Unplanned: p.Unplanned,
+	}
+	for _, t := range model.Schedule.Tasks {
+		for _, planned := range p.Planned {
+			if t.ID == planned.ID {
+				model.Planned = append(model.Planned, t)
+			}
+		}
+	}
+	return model
+}
+
+// PreviewPlan responds with a plan for fitting the backlog into
+// the breaks left in today's schedule, without changing anything.
+func (s *Server) PreviewPlan(w http.ResponseWriter, r *http.Request) {
+	s.plan(w, "")
+}
+
+// CommitPlan makes the plan given by the query token, which comes
+// from PreviewPlan, the live schedule, and removes the planned tasks
+// from the backlog. If the time, the backlog or the schedule have
+// changed so that the plan would be different, nothing is committed
+// and the response is 409 Conflict.
+func (s *Server) CommitPlan(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Please give the token of the plan to commit, from GET /v1/planner.", http.StatusBadRequest)
+		return
+	}
+	s.plan(w, token)
+}
+
+// plan responds with a plan for the backlog. If token is given,
+// the plan is committed to the live schedule if it matches.
+func (s *Server) plan(w http.ResponseWriter, token string) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+		return
+	}
+	backlog, err := s.loadBacklog()
+	if err != nil {
+		log.Printf("plan: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	proposal, err := tr.PlanBacklog(s.Schedule, backlog)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+
+	if token != "" && token != proposal.Token() {
+		http.Error(w, "The plan has changed since it was previewed. Please preview it again.", http.StatusConflict)
+		return
+	}
+
+	if token != "" && len(proposal.Planned) > 0 {
+		remaining := []tr.BacklogTask{}
+		for _, b := range backlog {
+			if !isPlanned(proposal, b) {
+				remaining = append(remaining, b)
+			}
+		}
+		err = s.saveBacklog(remaining)
+		if err != nil {
+			log.Printf("plan: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		previous := s.Schedule.CurrentTask
+		s.Schedule = proposal.Schedule
+		s.Changed()
+		if s.Schedule.CurrentTask != nil && !sameTask(previous, s.Schedule.CurrentTask) {
+			s.EmitCurrent(NewTaskModel(s.Schedule.CurrentTask))
+		}
+	}
+
+	err = tr.SendJson(NewProposalModel(proposal), w)
+	if err != nil {
+		log.Printf("plan: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// isPlanned returns true if the backlog task was
+// given a time in the proposal.
+func isPlanned(p tr.Proposal, b tr.BacklogTask) bool {
+	for _, t := range p.Planned {
+		if t.ID == b.ID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+func TestPlanner(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	backlog := func() []tr.BacklogTask {
+		w := serve(t, router, "GET", "/v1/backlog", "application/json", "")
+		var model map[string][]tr.BacklogTask
+		err := json.NewDecoder(w.Body).Decode(&model)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return model["backlog"]
+	}
+	proposal := func(w *httptest.ResponseRecorder) ProposalModel {
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var model ProposalModel
+		err := json.NewDecoder(w.Body).Decode(&model)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return model
+	}
+
+	w := serve(t, router, "POST", "/v1/backlog", "application/json", `[{"Description": "Email", "Estimate": "10m"}, {"Description": "Write", "Estimate": "1h", "Priority": 1}]`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = serve(t, router, "POST", "/v1/backlog", "application/json", `[{"Description": "Nothing", "Estimate": "0s"}]`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for a task without an estimate, got %d", http.StatusBadRequest, w.Code)
+	}
+	if len(backlog()) != 2 {
+		t.Fatalf("Expected 2 tasks in the backlog, got %v", backlog())
+	}
+
+	// Only the 10 minute break between the two tasks is free
+	model := proposal(serve(t, router, "GET", "/v1/planner", "application/json", ""))
+	if len(model.Planned) != 1 || model.Planned[0].Description != "Email" {
+		t.Fatalf("Expected Email to be planned, got %v", model.Planned)
+	}
+	if len(model.Unplanned) != 1 || model.Unplanned[0].Description != "Write" {
+		t.Fatalf("Expected Write not to fit, got %v", model.Unplanned)
+	}
+	if len(model.Schedule.Tasks) != 3 || !s.Schedule.Tasks[1].IsBreak() {
+		t.Fatalf("Expected a preview to leave the schedule unchanged, got %v", s.Schedule.Tasks)
+	}
+
+	w = serve(t, router, "POST", "/v1/planner", "application/json", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d without a token, got %d", http.StatusBadRequest, w.Code)
+	}
+	// Once the break has started, Email no longer fits, so the plan isn't committed
+	now := c.Now()
+	c.Set(now.Add(35 * time.Minute))
+	w = serve(t, router, "POST", "/v1/planner?token="+model.Token, "application/json", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d for a stale plan, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if len(backlog()) != 2 {
+		t.Fatalf("Expected the backlog to be unchanged, got %v", backlog())
+	}
+	c.Set(now)
+
+	model = proposal(serve(t, router, "POST", "/v1/planner?token="+model.Token, "application/json", ""))
+	if s.Schedule.Tasks[1].Description != "Email" || s.Schedule.Tasks[1].ID != model.Planned[0].ID {
+		t.Fatalf("Expected Email to be added to the schedule, got %v", s.Schedule.Tasks)
+	}
+	remaining := backlog()
+	if len(remaining) != 1 || remaining[0].Description != "Write" {
+		t.Fatalf("Expected only Write to be left in the backlog, got %v", remaining)
+	}
+
+	w = serve(t, router, "DELETE", "/v1/backlog/"+remaining[0].ID, "application/json", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	w = serve(t, router, "DELETE", "/v1/backlog/"+remaining[0].ID, "application/json", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if len(backlog()) != 0 {
+		t.Fatalf("Expected the backlog to be empty, got %v", backlog())
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+// This includes the handlers for plans, which are schedules staged
+// for days after today. Plans are kept in the state directory and
+// become the live schedule when their day begins (see RollOver).
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+	"github.com/gorilla/mux"
+)
+
+// planDay returns the day named by the request's date
+// variable, which must be after today.
+func (s *Server) planDay(r *http.Request) (time.Time, error) {
+	day, err := time.ParseInLocation(time.DateOnly, mux.Vars(r)["date"], s.now().Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("planDay: %w", err)
+	}
+	if !day.After(s.today()) {
+		return time.Time{}, errors.New("plans can only be made for days after today")
+	}
+	return day, nil
+}
+
+// loadPlan returns the plan staged for the given day,
+// or nil if there is none. The caller must hold s.mu.
+func (s *Server) loadPlan(day time.Time) (*tr.Schedule, error) {
+	name := s.planFile(day)
+	if name == "" {
+		return nil, nil
+	}
+	return s.buildFromFile(name, day)
+}
+
+// savePlan writes the plan for its day to the plans directory
+// as JSON, replacing any plan staged for that day in another
+// format. The caller must hold s.mu.
+func (s *Server) savePlan(plan *tr.Schedule) error {
+	if s.StateDir == "" {
+		return errors.New("savePlan: no state directory")
+	}
+	dir := filepath.Join(s.StateDir, plansDirName)
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("savePlan: %w", err)
+	}
+	tasks := make([]tr.Task, len(plan.Tasks))
+	for i, t := range plan.Tasks {
+		tasks[i] = *t
+	}
+	payload, err := json.MarshalIndent(tasks, "", "\t")
+	if err != nil {
+		return fmt.Errorf("savePlan: %w", err)
+	}
+
+	base := plan.Day.Format(time.DateOnly)
+	err = tr.WriteFileAtomic(filepath.Join(dir, base+".json"), payload, 0644)
+	if err != nil {
+		return fmt.Errorf("savePlan: %w", err)
+	}
+	for _, ext := range planExtensions[1:] {
+		err = os.Remove(filepath.Join(dir, base+ext))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("savePlan: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPlans lists the days that have a staged plan.
+func (s *Server) GetPlans(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dates := []string{}
+	entries, err := os.ReadDir(filepath.Join(s.StateDir, plansDirName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("GetPlans: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for _, e := range entries {
+		date := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		day, err := time.ParseInLocation(time.DateOnly, date, s.now().Location())
+		if err != nil || !day.After(s.today()) || s.planFile(day) == "" {
+			continue
+		}
+		if len(dates) == 0 || dates[len(dates)-1] != date {
+			dates = append(dates, date)
+		}
+	}
+	sort.Strings(dates)
+
+	err = tr.SendJson(map[string][]string{"plans": dates}, w)
+	if err != nil {
+		log.Printf("GetPlans: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetPlan responds with the plan staged for a day.
+func (s *Server) GetPlan(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	day, err := s.planDay(r)
+	if err != nil {
+		http.Error(w, "Please give a date after today, formatted as "+time.DateOnly+".", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan, err := s.loadPlan(day)
+	if err != nil {
+		log.Printf("GetPlan: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if plan == nil {
+		http.Error(w, "No plan has been made for that day.", http.StatusNotFound)
+		return
+	}
+	err = tr.SendJson(NewScheduleModel(plan), w)
+	if err != nil {
+		log.Printf("GetPlan: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// PlanSchedule stages the schedule for a day after today from
+// an uploaded file, in the same formats as BuildSchedule. A plan
+// that was already staged for the day is only replaced if the
+// request is a PUT.
+func (s *Server) PlanSchedule(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	day, err := s.planDay(r)
+	if err != nil {
+		http.Error(w, "Please give a date after today, formatted as "+time.DateOnly+".", http.StatusBadRequest)
+		return
+	}
+	body, format, err := buildFile(w, r)
+	if err != nil {
+		log.Printf("PlanSchedule: %s", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format == "" {
+		http.Error(w, "Please send a csv, iCalendar or JSON file.", http.StatusUnsupportedMediaType)
+		return
+	}
+	tasks, err := tr.ParseTasks(body, format, tr.StartOfDay(day, s.DayStart))
+	if err != nil {
+		writeBuildError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method != http.MethodPut && s.planFile(day) != "" {
+		http.Error(w, "A plan has already been made for that day. Use PUT to replace it.", http.StatusBadRequest)
+		return
+	}
+	plan := s.newSchedule(tr.TaskList{}, day)
+	err = plan.Replace(tasks...)
+	if err != nil {
+		writeBuildError(w, err)
+		return
+	}
+	s.respondWithPlan(w, plan)
+}
+
+// UpdatePlan updates the plan staged for a day with the tasks
+// in the request body, resolving conflicts the same way as
+// UpdateTasks does for the live schedule.
+func (s *Server) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	day, err := s.planDay(r)
+	if err != nil {
+		http.Error(w, "Please give a date after today, formatted as "+time.DateOnly+".", http.StatusBadRequest)
+		return
+	}
+	var tasks []tr.Task
+	err = json.NewDecoder(r.Body).Decode(&tasks)
+	if err != nil {
+		log.Printf("UpdatePlan: %s", err.Error())
+		http.Error(w, "Invalid HTTP Body", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plan, err := s.loadPlan(day)
+	if err != nil {
+		log.Printf("UpdatePlan: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if plan == nil {
+		http.Error(w, "No plan has been made for that day.", http.StatusNotFound)
+		return
+	}
+	err = plan.UpdateTimeBlock(tasks...)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	s.respondWithPlan(w, plan)
+}
+
+// DeletePlan removes the plan staged for a day.
+func (s *Server) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	day, err := s.planDay(r)
+	if err != nil {
+		http.Error(w, "Please give a date after today, formatted as "+time.DateOnly+".", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := s.planFile(day)
+	if name == "" {
+		http.Error(w, "No plan has been made for that day.", http.StatusNotFound)
+		return
+	}
+	for name != "" {
+		err = os.Remove(name)
+		if err != nil {
+			log.Printf("DeletePlan: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		name = s.planFile(day)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondWithPlan saves the plan and responds with it.
+// The caller must hold s.mu.
+func (s *Server) respondWithPlan(w http.ResponseWriter, plan *tr.Schedule) {
+	err := s.savePlan(plan)
+	if err != nil {
+		log.Printf("respondWithPlan: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = tr.SendJson(NewScheduleModel(plan), w)
+	if err != nil {
+		log.Printf("respondWithPlan: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+func TestPlans(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	w := serve(t, router, "POST", "/v1/plans/2024-03-10", "text/csv", "Plan,09:00,10:00\n")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for today's date, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = serve(t, router, "POST", "/v1/plans/2024-03-11", "text/csv", "Plan,09:00,10:00\n")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := descriptions(t, w); got != "[Plan]" {
+		t.Fatalf("Expected [Plan], got %s", got)
+	}
+	w = serve(t, router, "POST", "/v1/plans/2024-03-11", "text/csv", "Other,09:00,10:00\n")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an existing plan, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = serve(t, router, "PUT", "/v1/plans/2024-03-11", "text/csv", "Write,09:00,11:00\nRead,13:00,14:00\n")
+	if got := descriptions(t, w); got != "[Write Break Read]" {
+		t.Fatalf("Expected [Write Break Read], got %s", got)
+	}
+
+	// Edits resolve conflicts like updates to the live schedule do
+	day := time.Date(2024, time.March, 11, 0, 0, 0, 0, time.Local)
+	body, _ := json.Marshal([]tr.Task{tr.NewTask("Call", day.Add(10*time.Hour), day.Add(12*time.Hour))})
+	w = serve(t, router, "PATCH", "/v1/plans/2024-03-11", "application/json", string(body))
+	if got := descriptions(t, w); got != "[Write Call Break Read]" {
+		t.Fatalf("Expected [Write Call Break Read], got %s", got)
+	}
+	body, _ = json.Marshal([]tr.Task{tr.NewTask("Call", day.Add(34*time.Hour), day.Add(35*time.Hour))})
+	w = serve(t, router, "PATCH", "/v1/plans/2024-03-11", "application/json", string(body))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for a task on another day, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = serve(t, router, "GET", "/v1/plans", "", "")
+	if !strings.Contains(w.Body.String(), `"plans":["2024-03-11"]`) {
+		t.Fatalf("Expected the plan for 2024-03-11 to be listed, got %s", w.Body.String())
+	}
+
+	// The plan becomes the live schedule when its day arrives
+	c.Set(day)
+	s.RollOver()
+	if s.Schedule == nil || s.Schedule.Tasks[0].Description != "Write" {
+		t.Fatalf("Expected the plan to become the live schedule, got: %v", s.Schedule)
+	}
+	w = serve(t, router, "GET", "/v1/plans", "", "")
+	if !strings.Contains(w.Body.String(), `"plans":[]`) {
+		t.Fatalf("Expected no plans once the plan is live, got %s", w.Body.String())
+	}
+
+	w = serve(t, router, "DELETE", "/v1/plans/2024-03-12", "", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	serve(t, router, "POST", "/v1/plans/2024-03-12", "text/csv", "Plan,09:00,10:00\n")
+	w = serve(t, router, "DELETE", "/v1/plans/2024-03-12", "", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	w = serve(t, router, "GET", "/v1/plans/2024-03-12", "", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
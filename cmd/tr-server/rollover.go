@@ -0,0 +1,170 @@
+package main
+
+// This includes the code that ends one day and starts the next.
+// When the day is over, the live schedule is archived to the
+// state directory and cleared, and the new day's schedule is
+// built from a plan staged for it or from the server's template.
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+const (
+	archiveDirName = "archive"
+	plansDirName   = "plans"
+)
+
+// planExtensions are the extensions a staged plan can have,
+// in the order they are looked for.
+var planExtensions = []string{".json", ".csv", ".ics"}
+
+// today returns the day that the current time belongs to.
+func (s *Server) today() time.Time {
+	return tr.DayOf(s.now(), s.DayStart)
+}
+
+// RollOver archives the live schedule once its day is over, and
+// builds the new day's schedule if a plan or template is available.
+// It returns true if the live schedule changed, along with the
+// resulting change of the current task.
+func (s *Server) RollOver() (TransitionEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	today := s.today()
+	if s.Schedule != nil && !s.Schedule.Day.Before(today) {
+		return TransitionEvent{}, false
+	}
+	if s.Schedule == nil && s.lastDay.Equal(today) {
+		return TransitionEvent{}, false // Already looked for a schedule to build today
+	}
+	s.lastDay = today
+
+	e := TransitionEvent{At: s.now()}
+	changed := false
+	if s.Schedule != nil {
+		e.Previous = copyTask(s.Schedule.CurrentTask)
+		s.stopTracking()
+		s.archiveSchedule()
+		s.Schedule = nil
+		changed = true
+	}
+	sched, err := s.stagedSchedule(today)
+	if err != nil {
+		log.Printf("RollOver: %s", err.Error())
+	} else if sched != nil {
+		s.Schedule = sched
+		s.SaveSchedule()
+		e.Current = copyTask(sched.CurrentTask)
+		changed = true
+	}
+
+	return e, changed
+}
+
+// archiveSchedule moves the live schedule from the state
+// directory to the archive, in a file named for its day.
+// The caller must hold s.mu.
+func (s *Server) archiveSchedule() {
+	if s.StateDir == "" {
+		return
+	}
+	dir := filepath.Join(s.StateDir, archiveDirName)
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		log.Printf("archiveSchedule: %s", err.Error())
+		return
+	}
+	name := filepath.Join(dir, s.Schedule.Day.Format(time.DateOnly)+".json")
+	err = s.Schedule.SaveToFile(name, s.Schedule.Day)
+	if err != nil {
+		log.Printf("archiveSchedule: %s", err.Error())
+		return
+	}
+	err = os.Remove(filepath.Join(s.StateDir, scheduleFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("archiveSchedule: %s", err.Error())
+	}
+}
+
+// planFile returns the name of the plan staged for the given
+// day, or an empty string if there is none.
+func (s *Server) planFile(day time.Time) string {
+	if s.StateDir == "" {
+		return ""
+	}
+	for _, ext := range planExtensions {
+		name := filepath.Join(s.StateDir, plansDirName, day.Format(time.DateOnly)+ext)
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// stagedSchedule builds the schedule for the given day from the
+// plan staged for it, or else from the server's template, which
+// is either the name of a stored template or a file. It returns
+// nil if there is neither. A plan is removed once it has been
+// used, since the live schedule takes its place.
+func (s *Server) stagedSchedule(day time.Time) (*tr.Schedule, error) {
+	if name := s.planFile(day); name != "" {
+		sched, err := s.buildFromFile(name, day)
+		if err != nil {
+			return nil, fmt.Errorf("stagedSchedule: %w", err)
+		}
+		err = os.Remove(name)
+		if err != nil {
+			log.Printf("stagedSchedule: %s", err.Error())
+		}
+		return sched, nil
+	}
+	if s.Template == "" {
+		return nil, nil
+	}
+	tmpl, err := s.loadTemplate(s.Template)
+	if err != nil {
+		return nil, fmt.Errorf("stagedSchedule: %w", err)
+	}
+	if tmpl != nil {
+		return s.templateSchedule(tmpl, day)
+	}
+	sched, err := s.buildFromFile(s.Template, day)
+	if err != nil {
+		return nil, fmt.Errorf("stagedSchedule: %w", err)
+	}
+	return sched, nil
+}
+
+// buildFromFile builds the schedule for the given day from
+// the file with the given name.
+func (s *Server) buildFromFile(name string, day time.Time) (*tr.Schedule, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("buildFromFile: %w", err)
+	}
+	defer f.Close()
+	tasks, err := tr.ParseTasks(f, tr.FormatFromFileName(name), tr.StartOfDay(day, s.DayStart))
+	if err != nil {
+		return nil, fmt.Errorf("buildFromFile: %s: %w", name, err)
+	}
+	tl, err := tr.NewTaskList(tasks...)
+	if err != nil {
+		return nil, fmt.Errorf("buildFromFile: %s: %w", name, err)
+	}
+
+	return s.newSchedule(tl, day), nil
+}
+
+// newSchedule returns a schedule of the given tasks
+// for the given day.
+func (s *Server) newSchedule(tl tr.TaskList, day time.Time) *tr.Schedule {
+	sched := tr.NewSchedule(tl, tr.ScheduleOptions{Clock: s.clock(), DayStart: s.DayStart, Day: day})
+	return &sched
+}
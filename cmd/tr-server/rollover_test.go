@@ -0,0 +1,112 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+func TestRollOver(t *testing.T) {
+	s, c := newTestServer(t)
+	s.Template = filepath.Join(t.TempDir(), "template.csv")
+	err := os.WriteFile(s.Template, []byte("Template Task,09:00,10:00\n"), 0644)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	err = os.MkdirAll(filepath.Join(s.StateDir, plansDirName), os.ModePerm)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	err = os.WriteFile(filepath.Join(s.StateDir, plansDirName, "2024-03-11.csv"), []byte("Planned Task,09:00,10:00\n"), 0644)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	s.SaveSchedule()
+
+	if _, ok := s.RollOver(); ok {
+		t.Fatalf("Expected no roll over during the day")
+	}
+
+	// The new day starts at 04:00, so just after midnight is still the 10th
+	s.DayStart = 4 * time.Hour
+	c.Set(time.Date(2024, time.March, 11, 0, 30, 0, 0, time.Local))
+	if _, ok := s.RollOver(); ok {
+		t.Fatalf("Expected no roll over before the day start")
+	}
+
+	c.Set(time.Date(2024, time.March, 11, 4, 0, 0, 0, time.Local))
+	if _, ok := s.RollOver(); !ok {
+		t.Fatalf("Expected roll over at the day start")
+	}
+	archived, err := tr.ReadFromFile(filepath.Join(s.StateDir, archiveDirName, "2024-03-10.json"), time.Local, s.DayStart)
+	if err != nil {
+		t.Fatalf("Expected the previous day to be archived: %s", err.Error())
+	}
+	if archived.Day.Format(time.DateOnly) != "2024-03-10" {
+		t.Fatalf("Expected the archive to be for the 10th, got %v", archived.Day)
+	}
+	if len(archived.Tasks) != 3 {
+		t.Fatalf("Expected 3 archived tasks, got: %s", archived.String())
+	}
+	if s.Schedule == nil || s.Schedule.Tasks[0].Description != "Planned Task" ||
+		s.Schedule.Day.Format(time.DateOnly) != "2024-03-11" {
+		t.Fatalf("Expected the staged plan to be built for the 11th, got: %v", s.Schedule)
+	}
+	if _, ok := s.RollOver(); ok {
+		t.Fatalf("Expected only one roll over per day")
+	}
+
+	c.Set(time.Date(2024, time.March, 12, 4, 0, 0, 0, time.Local))
+	if _, ok := s.RollOver(); !ok {
+		t.Fatalf("Expected roll over at the day start")
+	}
+	if s.Schedule == nil || s.Schedule.Tasks[0].Description != "Template Task" {
+		t.Fatalf("Expected the template to be built without a plan, got: %v", s.Schedule)
+	}
+
+	s.Template = ""
+	c.Set(time.Date(2024, time.March, 13, 4, 0, 0, 0, time.Local))
+	if _, ok := s.RollOver(); !ok || s.Schedule != nil {
+		t.Fatalf("Expected the schedule to be cleared, got: %v", s.Schedule)
+	}
+	if _, err := os.Stat(filepath.Join(s.StateDir, scheduleFileName)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the live schedule file to be removed, got: %v", err)
+	}
+}
+
+func TestSchedulerRollOver(t *testing.T) {
+	s, c := newTestServer(t)
+	err := os.MkdirAll(filepath.Join(s.StateDir, plansDirName), os.ModePerm)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	err = os.WriteFile(filepath.Join(s.StateDir, plansDirName, "2024-03-11.csv"), []byte("Early Task,00:00,01:00\n"), 0644)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	c.Set(time.Date(2024, time.March, 10, 23, 0, 0, 0, time.Local))
+
+	s.Scheduler = NewScheduler(s)
+	events := make(chan TransitionEvent, 8)
+	s.Scheduler.Subscribe(func(e TransitionEvent) {
+		events <- e
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go s.Scheduler.Run(done)
+
+	// The only thing left to wake for today is the end of the day
+	e := nextEvent(t, events)
+	if e.Current != nil {
+		t.Fatalf("Expected transition to no task, got: %+v", e)
+	}
+	waitForTimer(t, c)
+	c.Advance(time.Hour)
+	e = nextEvent(t, events)
+	if e.Current == nil || e.Current.Description != "Early Task" {
+		t.Fatalf("Expected transition to Early Task, got: %+v", e)
+	}
+}
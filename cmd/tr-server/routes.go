@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Routes returns the router for the server's API. The versioned
+// routes under /v1 are routed by method, so a request with the
+// wrong method gets a 405 response. The original unversioned
+// routes are kept as deprecated aliases until clients migrate.
+func (s *Server) Routes() *mux.Router {
+	router := mux.NewRouter()
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Method not allowed.", http.StatusMethodNotAllowed)
+	})
+
+	v1 := router.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/schedule", s.GetSchedule).Methods("GET")
+	v1.HandleFunc("/schedule", s.BuildSchedule).Methods("POST")
+	v1.HandleFunc("/schedule", s.UpdateTasks).Methods("PUT")
+	v1.HandleFunc("/schedule/tasks", s.ReplaceSchedule).Methods("PUT")
+	v1.HandleFunc("/schedule.ics", s.GetScheduleICS).Methods("GET")
+	v1.HandleFunc("/current", s.GetCurrentTask).Methods("GET")
+	v1.HandleFunc("/current", s.ChangeCurrentTask).Methods("POST")
+	v1.HandleFunc("/undo", s.Undo).Methods("POST")
+	v1.HandleFunc("/redo", s.Redo).Methods("POST")
+	v1.HandleFunc("/tasks/{id}", s.GetTask).Methods("GET")
+	v1.HandleFunc("/tasks/{id}", s.PatchTask).Methods("PATCH")
+	v1.HandleFunc("/tasks/{id}", s.DeleteTask).Methods("DELETE")
+	v1.HandleFunc("/plans", s.GetPlans).Methods("GET")
+	v1.HandleFunc("/plans/{date}", s.GetPlan).Methods("GET")
+	v1.HandleFunc("/plans/{date}", s.PlanSchedule).Methods("POST", "PUT")
+	v1.HandleFunc("/plans/{date}", s.UpdatePlan).Methods("PATCH")
+	v1.HandleFunc("/plans/{date}", s.DeletePlan).Methods("DELETE")
+	v1.HandleFunc("/backlog", s.GetBacklog).Methods("GET")
+	v1.HandleFunc("/backlog", s.AddToBacklog).Methods("POST")
+	v1.HandleFunc("/backlog/{id}", s.DeleteFromBacklog).Methods("DELETE")
+	v1.HandleFunc("/planner", s.PreviewPlan).Methods("GET")
+	v1.HandleFunc("/planner", s.CommitPlan).Methods("POST")
+	v1.HandleFunc("/templates", s.GetTemplates).Methods("GET")
+	v1.HandleFunc("/templates/{name}", s.GetTemplate).Methods("GET")
+	v1.HandleFunc("/templates/{name}", s.PutTemplate).Methods("PUT")
+	v1.HandleFunc("/templates/{name}", s.DeleteTemplate).Methods("DELETE")
+	v1.HandleFunc("/templates/{name}/apply", s.ApplyTemplate).Methods("POST")
+	v1.HandleFunc("/actuals", s.GetActuals).Methods("GET")
+	v1.HandleFunc("/actuals/start", s.StartActual).Methods("POST")
+	v1.HandleFunc("/actuals/stop", s.StopActual).Methods("POST")
+	v1.HandleFunc("/actuals/skip", s.SkipActual).Methods("POST")
+
+	router.HandleFunc("/get", deprecated("/v1/schedule", s.GetSchedule))
+	router.HandleFunc("/build", deprecated("/v1/schedule", s.BuildSchedule))
+	router.HandleFunc("/update", deprecated("/v1/schedule", s.UpdateTasks))
+	router.HandleFunc("/current", deprecated("/v1/current", s.GetCurrentTask))
+	router.HandleFunc("/change_current", deprecated("/v1/current", s.ChangeCurrentTask))
+
+	// The feed is served at the root as well, since calendar clients
+	// subscribe to it by URL and never need to migrate.
+	router.HandleFunc("/schedule.ics", s.GetScheduleICS).Methods("GET")
+
+	return router
+}
+
+// deprecated wraps a handler for a legacy route so that its
+// responses tell the client which route replaces it.
+func deprecated(successor string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+">; rel=\"successor-version\"")
+		h(w, r)
+	}
+}
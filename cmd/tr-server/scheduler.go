@@ -0,0 +1,213 @@
+package main
+
+// This includes the code that tracks transitions between tasks.
+// Rather than polling, the Scheduler sleeps until the next time
+// a task starts or ends (or a warning is due, or the day ends),
+// and is re-armed whenever the schedule changes so that it never
+// sleeps past a new boundary.
+
+import (
+	"sync"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+// TransitionEvent describes a change of the current task.
+// Previous and Current are nil when there was or is no task.
+type TransitionEvent struct {
+	Previous *tr.Task
+	Current  *tr.Task
+	At       time.Time
+}
+
+// Scheduler emits a TransitionEvent to its subscribers
+// each time the server's current task changes, and a
+// Warning each time a task is about to end.
+type Scheduler struct {
+	server *Server
+	rearm  chan struct{}
+
+	mu                 sync.Mutex
+	subscribers        []func(TransitionEvent)
+	warningSubscribers []func(tr.Warning)
+}
+
+// NewScheduler returns a Scheduler for the given server.
+func NewScheduler(s *Server) *Scheduler {
+	return &Scheduler{
+		server: s,
+		rearm:  make(chan struct{}, 1),
+	}
+}
+
+// Subscribe registers f to be called with every
+// transition. Subscribers are called in order on
+// the scheduler's goroutine.
+func (sc *Scheduler) Subscribe(f func(TransitionEvent)) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.subscribers = append(sc.subscribers, f)
+}
+
+// SubscribeWarnings registers f to be called with every
+// warning that a task is about to end.
+func (sc *Scheduler) SubscribeWarnings(f func(tr.Warning)) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.warningSubscribers = append(sc.warningSubscribers, f)
+}
+
+// Rearm tells the scheduler that the schedule has changed,
+// so it should recompute the next boundary. It never blocks.
+func (sc *Scheduler) Rearm() {
+	select {
+	case sc.rearm <- struct{}{}:
+	default:
+	}
+}
+
+// Run waits for task boundaries, warnings and the start of each
+// day, and emits them until done is closed. It should be run on
+// its own goroutine.
+func (sc *Scheduler) Run(done <-chan struct{}) {
+	last := sc.server.now()
+	for {
+		if e, ok := sc.server.RollOver(); ok && !sameTask(e.Previous, e.Current) {
+			sc.publish(e)
+		}
+		sc.check()
+		last = sc.warn(last)
+
+		timer := sc.server.clock().NewTimer(sc.server.NextWake().Sub(sc.server.now()))
+		select {
+		case <-done:
+			timer.Stop()
+			return
+		case <-sc.rearm:
+			timer.Stop()
+		case <-timer.C():
+		}
+	}
+}
+
+// check publishes a transition if the current task has changed.
+func (sc *Scheduler) check() {
+	e, ok := sc.server.CheckCurrentTask()
+	if ok {
+		sc.publish(e)
+	}
+}
+
+// publish sends a transition to every subscriber.
+func (sc *Scheduler) publish(e TransitionEvent) {
+	sc.mu.Lock()
+	subscribers := append([]func(TransitionEvent){}, sc.subscribers...)
+	sc.mu.Unlock()
+	for _, f := range subscribers {
+		f(e)
+	}
+}
+
+// warn publishes the warnings that have become due since the
+// given time, and returns the time it checked up to.
+func (sc *Scheduler) warn(since time.Time) time.Time {
+	warnings, now := sc.server.DueWarnings(since)
+	sc.mu.Lock()
+	subscribers := append([]func(tr.Warning){}, sc.warningSubscribers...)
+	sc.mu.Unlock()
+	for _, w := range warnings {
+		for _, f := range subscribers {
+			f(w)
+		}
+	}
+	return now
+}
+
+// NextWake returns the next time the scheduler has something
+// to do: either the live schedule's current task will change,
+// a warning will be due, or a new day will begin.
+func (s *Server) NextWake() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	wake := tr.NextDayStart(now, s.DayStart)
+	if s.Schedule == nil {
+		return wake
+	}
+	if next, ok := s.Schedule.Tasks.NextBoundary(now); ok && next.Before(wake) {
+		wake = next
+	}
+	if w, found := s.Warnings.NextWarning(s.Schedule.Tasks, now); found && w.At.Before(wake) {
+		wake = w.At
+	}
+	return wake
+}
+
+// DueWarnings returns the warnings that have become due
+// since the given time, and the time it checked up to.
+func (s *Server) DueWarnings(since time.Time) ([]tr.Warning, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.Schedule == nil {
+		return nil, now
+	}
+	return s.Warnings.WarningsBetween(s.Schedule.Tasks, since, now), now
+}
+
+// CheckCurrentTask updates the schedule's current task to
+// match the current time. If the current task has changed,
+// it returns the transition and true.
+func (s *Server) CheckCurrentTask() (TransitionEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		return TransitionEvent{}, false
+	}
+	previous := s.Schedule.CurrentTask
+	s.Schedule.UpdateCurrentTask()
+	s.trackCurrent()
+	current := s.Schedule.CurrentTask
+	if sameTask(previous, current) {
+		return TransitionEvent{}, false
+	}
+	s.SaveSchedule()
+
+	return TransitionEvent{
+		Previous: copyTask(previous),
+		Current:  copyTask(current),
+		At:       s.now(),
+	}, true
+}
+
+// Changed is called after every change to the live schedule. It
+// saves the schedule, records any change of the current task in
+// the actuals log and re-arms the scheduler. The caller must hold
+// s.mu.
+func (s *Server) Changed() {
+	s.SaveSchedule()
+	s.trackCurrent()
+	if s.Scheduler != nil {
+		s.Scheduler.Rearm()
+	}
+}
+
+// sameTask returns true if a and b refer to the same
+// task with the same times, or are both nil.
+func sameTask(a, b *tr.Task) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ID == b.ID && a.StartTime.Equal(b.StartTime) && a.EndTime.Equal(b.EndTime)
+}
+
+// copyTask returns a copy of t, so that it can be
+// used without holding the server's lock.
+func copyTask(t *tr.Task) *tr.Task {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+// waitForTimer blocks until the fake clock has a pending timer.
+func waitForTimer(t *testing.T, c *tr.FakeClock) {
+	deadline := time.Now().Add(time.Second)
+	for c.Timers() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Scheduler never set a timer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// nextEvent returns the next transition sent on events.
+func nextEvent(t *testing.T, events <-chan TransitionEvent) TransitionEvent {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a transition event")
+	}
+	return TransitionEvent{}
+}
+
+func TestSchedulerTransitions(t *testing.T) {
+	s, c := newTestServer(t)
+	s.Scheduler = NewScheduler(s)
+	events := make(chan TransitionEvent, 8)
+	s.Scheduler.Subscribe(func(e TransitionEvent) {
+		events <- e
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go s.Scheduler.Run(done)
+
+	waitForTimer(t, c)
+	c.Advance(30 * time.Minute)
+	e := nextEvent(t, events)
+	if e.Previous == nil || e.Previous.Description != "Task 1" || e.Current == nil || !e.Current.IsBreak() {
+		t.Fatalf("Expected transition from Task 1 to a break, got: %+v", e)
+	}
+	if e.At.Format(time.TimeOnly) != "12:30:00" {
+		t.Fatalf("Expected transition at 12:30:00, got %s", e.At.Format(time.TimeOnly))
+	}
+
+	waitForTimer(t, c)
+	c.Advance(10 * time.Minute)
+	e = nextEvent(t, events)
+	if e.Current == nil || e.Current.Description != "Task 2" {
+		t.Fatalf("Expected transition to Task 2, got: %+v", e)
+	}
+
+	// Inserting a task re-arms the scheduler for the new boundary
+	waitForTimer(t, c)
+	start := c.Now().Add(20 * time.Minute)
+	body, _ := json.Marshal([]tr.Task{tr.NewTask("Inserted", start, start.Add(10*time.Minute))})
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, httptest.NewRequest("PUT", "/v1/schedule", strings.NewReader(string(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	waitForTimer(t, c)
+	c.Advance(20 * time.Minute)
+	e = nextEvent(t, events)
+	if e.Current == nil || e.Current.Description != "Inserted" {
+		t.Fatalf("Expected transition to Inserted, got: %+v", e)
+	}
+
+	select {
+	case e := <-events:
+		t.Fatalf("Unexpected transition: %+v", e)
+	default:
+	}
+}
+
+func TestSchedulerWarnings(t *testing.T) {
+	s, c := newTestServer(t)
+	s.Warnings = tr.WarningPolicy{Default: []time.Duration{10 * time.Minute}}
+	s.Scheduler = NewScheduler(s)
+	transitions := make(chan TransitionEvent, 8)
+	warnings := make(chan tr.Warning, 8)
+	s.Scheduler.Subscribe(func(e TransitionEvent) {
+		transitions <- e
+	})
+	s.Scheduler.SubscribeWarnings(func(w tr.Warning) {
+		warnings <- w
+	})
+	done := make(chan struct{})
+	defer close(done)
+	go s.Scheduler.Run(done)
+
+	waitForTimer(t, c)
+	c.Advance(20 * time.Minute)
+	select {
+	case w := <-warnings:
+		if w.Task.Description != "Task 1" || w.At.Format(time.TimeOnly) != "12:20:00" || w.Next == nil || w.Next.Description != "Task 2" {
+			t.Fatalf("Expected warning for Task 1 at 12:20:00 followed by Task 2, got: %+v", w)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a warning")
+	}
+	select {
+	case e := <-transitions:
+		t.Fatalf("Unexpected transition before Task 1 ends: %+v", e)
+	default:
+	}
+
+	waitForTimer(t, c)
+	c.Advance(10 * time.Minute)
+	e := nextEvent(t, transitions)
+	if e.Current == nil || !e.Current.IsBreak() {
+		t.Fatalf("Expected transition to a break, got: %+v", e)
+	}
+}
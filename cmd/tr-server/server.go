@@ -1,25 +1,48 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	tr "github.com/dethancosta/timeruler/internal"
+	"github.com/gorilla/mux"
 )
 
 type Server struct {
 	Owner string // TODO replace with actual credentials for auth
 	Addr  string // Address of the server
-	Ntfy  string
 
+	Notifier Notifier         // Where events are sent; may be nil
+	Warnings tr.WarningPolicy // When to warn that a task is ending
+
+	startNotifiers sync.Once      // Starts the goroutines that send events
+	queues         []chan<- Event // Events waiting to be sent, one queue per notifier; see Emit
+
+	StateDir string   // Directory the live schedule is saved to
+	Clock    tr.Clock // Source of the current time; the system clock if nil
+
+	DayStart time.Duration // Time after midnight at which each day begins
+	Template string        // Template or file each day's schedule is built from if no plan is staged; may be empty
+
+	// mu guards Schedule, which is shared between the
+	// HTTP handlers and the goroutine that tracks the
+	// current task.
+	mu       sync.Mutex
 	Schedule *tr.Schedule
+
+	Scheduler *Scheduler // Emits transitions between tasks; may be nil
+	lastDay   time.Time  // The last day RollOver looked for a schedule to build
+	tracked   string     // The ID of the task last recorded as started in the actuals log
 }
 
 type TaskModel struct {
@@ -28,84 +51,152 @@ type TaskModel struct {
 	Until       string `json:"Until"`
 }
 
+// NewTaskModel returns the TaskModel for the given task.
+func NewTaskModel(t *tr.Task) TaskModel {
+	return TaskModel{
+		t.Description,
+		t.Tag,
+		t.EndTime.Format(time.TimeOnly),
+	}
+}
+
+// ScheduleModel is the JSON representation of a schedule.
+type ScheduleModel struct {
+	Tasks []ScheduleTaskModel `json:"tasks"`
+}
+
+// ScheduleTaskModel is the JSON representation of
+// a single task within a ScheduleModel.
+type ScheduleTaskModel struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Tag         string `json:"tag"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	IsBreak     bool   `json:"is_break"`
+	IsCurrent   bool   `json:"is_current"`
+	IsFixed     bool   `json:"is_fixed"`
+}
+
+// NewScheduleModel returns the JSON representation of
+// the given schedule, with times formatted as RFC 3339.
+func NewScheduleModel(sched *tr.Schedule) ScheduleModel {
+	model := ScheduleModel{
+		Tasks: make([]ScheduleTaskModel, len(sched.Tasks)),
+	}
+	for i, t := range sched.Tasks {
+		model.Tasks[i] = NewScheduleTaskModel(t, i == sched.CurrentID)
+	}
+	return model
+}
+
+// NewScheduleTaskModel returns the JSON representation of a
+// task, with times formatted as RFC 3339.
+func NewScheduleTaskModel(t *tr.Task, current bool) ScheduleTaskModel {
+	return ScheduleTaskModel{
+		ID:          t.ID,
+		Description: t.Description,
+		Tag:         t.Tag,
+		Start:       t.StartTime.Format(time.RFC3339),
+		End:         t.EndTime.Format(time.RFC3339),
+		IsBreak:     t.IsBreak(),
+		IsCurrent:   current,
+		IsFixed:     t.Fixed,
+	}
+}
+
+// GetSchedule responds with the schedule, marking the task at the
+// current time. It doesn't change the schedule's current task, which
+// is left to the scheduler.
 func (s *Server) GetSchedule(w http.ResponseWriter, r *http.Request) {
-	// TODO test
 	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.Schedule == nil {
 		http.Error(w, "No schedule has been built yet.", http.StatusNotFound)
 		return
 	}
-	current, idx := s.Schedule.Tasks.GetTaskAtTime(time.Now())
-	if idx == -1 {
-		s.Schedule.CurrentTask = nil
-		s.Schedule.CurrentID = -1
-	} else if idx != s.Schedule.CurrentID {
-		err := s.Schedule.UpdateCurrentTask()
+	view := *s.Schedule
+	view.CurrentTask, view.CurrentID = view.Tasks.GetTaskAtTime(s.now())
+	if prefersText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, view.String())
+		return
+	}
+	err := tr.SendJson(NewScheduleModel(&view), w)
+	if err != nil {
+		log.Printf("GetSchedule: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetScheduleICS serves the schedule as an iCalendar feed that
+// calendar clients can subscribe to. Breaks are included unless
+// the request has the query breaks=false.
+func (s *Server) GetScheduleICS(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	breaks := true
+	if q := r.URL.Query().Get("breaks"); q != "" {
+		var err error
+		breaks, err = strconv.ParseBool(q)
 		if err != nil {
-			log.Printf("GetSchedule: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
+			http.Error(w, "Invalid value for breaks.", http.StatusBadRequest)
 			return
 		}
-		if s.Ntfy != "" {
-			currentModel := TaskModel{
-				current.Description,
-				current.Tag,
-				current.EndTime.Format(time.TimeOnly),
-			}
-			err = s.NtfyNewCurrent(s.Ntfy, currentModel)
-			if err != nil {
-				log.Printf("GetSchedule: %s", err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			// TODO delete this line
-			log.Println("ntfy sent")
-		}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	body := make(map[string]string)
-	body["Schedule"] = s.Schedule.String()
-	msg, err := json.Marshal(body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusNotFound)
+		return
+	}
+	tasks := s.Schedule.Tasks
+	if !breaks {
+		tasks = tasks.WithoutBreaks()
+	}
+	var buf bytes.Buffer
+	err := tasks.WriteICS(&buf, s.now())
 	if err != nil {
-		log.Printf("GetSchedule: %s", err.Error())
+		log.Printf("GetScheduleICS: %s", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.Write(msg)
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// prefersText returns true if the request's Accept header
+// lists text/plain before application/json. JSON is the
+// default when neither is listed.
+func prefersText(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accepted, ";")
+		switch strings.TrimSpace(mediaType) {
+		case "text/plain":
+			return true
+		case "application/json":
+			return false
+		}
+	}
+	return false
 }
 
 func (s *Server) GetCurrentTask(w http.ResponseWriter, r *http.Request) {
-	// TODO test
 	// TODO authenticate
-	current, idx := s.Schedule.Tasks.GetTaskAtTime(time.Now())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusNotFound)
+		return
+	}
+	current, _ := s.Schedule.Tasks.GetTaskAtTime(s.now())
 	if current == nil {
 		http.Error(w, "No current task found.", http.StatusNotFound)
 		return
 	}
-	if idx != s.Schedule.CurrentID {
-		err := s.Schedule.UpdateCurrentTask()
-		if err != nil {
-			log.Printf("GetCurrentTask: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		if s.Ntfy != "" {
-			currentModel := TaskModel{
-				current.Description,
-				current.Tag,
-				current.EndTime.Format(time.TimeOnly),
-			}
-			err = s.NtfyNewCurrent(s.Ntfy, currentModel)
-			if err != nil {
-				log.Printf("GetSchedule: %s", err.Error())
-				w.WriteHeader(http.StatusInternalServerError)
-				return
-			}
-			// TODO delete this line
-			log.Println("ntfy sent")
-		}
-	}
 	msg, err := json.Marshal(map[string]struct {
 		Description string `json:"Description"`
 		Tag         string `json:"Tag"`
@@ -126,11 +217,20 @@ func (s *Server) GetCurrentTask(w http.ResponseWriter, r *http.Request) {
 	w.Write(msg)
 }
 
+// ChangeCurrentTask replaces the current task with the one in the
+// request body until the given time. With the query dry_run=true,
+// the schedule is left unchanged and the response is the changes
+// that would be made to it.
 func (s *Server) ChangeCurrentTask(w http.ResponseWriter, r *http.Request) {
-	// TODO test
 	// TODO authenticate
+	dryRun, err := isDryRun(r)
+	if err != nil {
+		http.Error(w, "Invalid value for dry_run.", http.StatusBadRequest)
+		return
+	}
+
 	var taskModel TaskModel
-	err := json.NewDecoder(r.Body).Decode(&taskModel)
+	err = json.NewDecoder(r.Body).Decode(&taskModel)
 	if err != nil {
 		log.Printf("ChangeCurrentTask: %s", err.Error())
 		http.Error(w, "Invalid HTTP Body", http.StatusBadRequest)
@@ -139,154 +239,566 @@ func (s *Server) ChangeCurrentTask(w http.ResponseWriter, r *http.Request) {
 
 	// TODO validate time
 	end, err := time.Parse(time.TimeOnly, taskModel.Until)
-	now := time.Now()
-	end = time.Date(now.Year(), now.Month(), now.Day(), end.Hour(), end.Minute(), 0, 0, time.Local)
 	if err != nil {
 		log.Printf("ChangeCurrentTask: %s", err)
 		http.Error(w, fmt.Sprintf("Please give the time in the following format: %s", time.TimeOnly), http.StatusBadRequest)
 		return
 	}
-	err = s.Schedule.ChangeCurrentTaskUntil(taskModel.Description, taskModel.Tag, end)
+	now := s.now()
+	end = time.Date(now.Year(), now.Month(), now.Day(), end.Hour(), end.Minute(), 0, 0, now.Location())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+		return
+	}
+	if _, dayEnd := s.Schedule.Bounds(); s.DayStart > 0 && !end.After(now) && !end.AddDate(0, 0, 1).After(dayEnd) {
+		// The task runs past midnight, but ends before the day does
+		end = end.AddDate(0, 0, 1)
+	}
+	sched := s.Schedule
+	if dryRun {
+		sched = s.Schedule.Clone()
+	}
+	err = sched.ChangeCurrentTaskUntil(taskModel.Description, taskModel.Tag, end)
 	if err != nil {
 		log.Printf("ChangeCurrentTask: %s", err.Error())
-		if errors.Is(err, tr.InvalidTimeError{}) {
-			http.Error(w, "Please give a valid time for the task to finish.", http.StatusBadRequest)
+		if errors.As(err, &tr.InvalidTimeError{}) {
+			http.Error(w, "Please give a valid time for the task to finish. "+err.Error(), http.StatusBadRequest)
 		} else {
 			http.Error(w, "Encountered an internal server error.", http.StatusInternalServerError)
 		}
 		return
 	}
-	if s.Ntfy != "" {
-		currentModel := taskModel
-		err = s.NtfyNewCurrent(s.Ntfy, currentModel)
-		if err != nil {
-			log.Printf("GetSchedule: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		log.Println("ntfy sent")
+	sched.UpdateCurrentTask()
+	if dryRun {
+		s.respondWithDiff(w, sched, nil, nil)
+		return
 	}
+	s.Changed()
+	s.EmitCurrent(taskModel)
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) UpdateTasks(w http.ResponseWriter, r *http.Request) {
+// OverflowModel is the JSON representation of the tasks
+// that were pushed out of the day by an update.
+type OverflowModel struct {
+	Overflow []ScheduleTaskModel `json:"overflow"`
+}
 
-	if s.Schedule == nil {
-		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+// NewOverflowModel returns the JSON representation of overflow.
+func NewOverflowModel(overflow []tr.Task) OverflowModel {
+	model := OverflowModel{Overflow: make([]ScheduleTaskModel, len(overflow))}
+	for i := range overflow {
+		model.Overflow[i] = NewScheduleTaskModel(&overflow[i], false)
+	}
+	return model
+}
+
+// TaskChangeModel is the JSON representation of a single
+// change that an update would make to the schedule.
+type TaskChangeModel struct {
+	Kind   string              `json:"kind"`
+	Before []ScheduleTaskModel `json:"before"`
+	After  []ScheduleTaskModel `json:"after"`
+}
+
+// DiffModel is the JSON representation of the changes that an
+// update would make to the schedule, and the schedule it would
+// make.
+type DiffModel struct {
+	Changes  []TaskChangeModel   `json:"changes"`
+	Overflow []ScheduleTaskModel `json:"overflow"`
+	Schedule ScheduleModel       `json:"schedule"`
+}
+
+// NewDiffModel returns the JSON representation of the changes
+// from before to after, and the tasks that no longer fit. The
+// tasks with the given IDs were added by the update.
+func NewDiffModel(before, after *tr.Schedule, overflow []tr.Task, added []string) DiffModel {
+	d := tr.DiffTaskLists(before.Tasks, after.Tasks, added...)
+	model := DiffModel{
+		Changes:  make([]TaskChangeModel, len(d.Changes)),
+		Overflow: NewOverflowModel(overflow).Overflow,
+		Schedule: NewScheduleModel(after),
+	}
+	for i, c := range d.Changes {
+		change := TaskChangeModel{
+			Kind:   string(c.Kind),
+			Before: make([]ScheduleTaskModel, len(c.Before)),
+			After:  make([]ScheduleTaskModel, len(c.After)),
+		}
+		for j := range c.Before {
+			change.Before[j] = NewScheduleTaskModel(&c.Before[j], false)
+		}
+		for j := range c.After {
+			change.After[j] = NewScheduleTaskModel(&c.After[j], false)
+		}
+		model.Changes[i] = change
+	}
+	return model
+}
+
+// respondWithDiff responds with the changes from the schedule to
+// the proposed one, which is left unused, given the IDs of the
+// tasks the update added. The caller must hold s.mu.
+func (s *Server) respondWithDiff(w http.ResponseWriter, proposed *tr.Schedule, overflow []tr.Task, added []string) {
+	err := tr.SendJson(NewDiffModel(s.Schedule, proposed, overflow, added), w)
+	if err != nil {
+		log.Printf("respondWithDiff: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UpdateTasks adds the tasks in the request body to the schedule.
+// The query strategy chooses how conflicts with existing tasks are
+// resolved: shift-later (the default), split, shrink-to-fit, reject
+// or compress. The response lists any tasks that no longer fit in
+// the day. With the query dry_run=true, the schedule is left
+// unchanged and the response is the changes that would be made
+// to it.
+func (s *Server) UpdateTasks(w http.ResponseWriter, r *http.Request) {
+	cs, err := tr.ParseConflictStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun, err := isDryRun(r)
+	if err != nil {
+		http.Error(w, "Invalid value for dry_run.", http.StatusBadRequest)
 		return
 	}
 
 	var tasks []tr.Task
-
-	err := json.NewDecoder(r.Body).Decode(&tasks)
+	err = json.NewDecoder(r.Body).Decode(&tasks)
 	if err != nil {
 		log.Printf("UpdateTasks: %s", err.Error())
 		http.Error(w, "Invalid HTTP Body", http.StatusBadRequest)
 		return
 	}
 
-	now := time.Now()
-	for _, t := range tasks {
-		if t.StartTime.Before(now) {
+	now := s.now()
+	added := make([]string, len(tasks))
+	for i := range tasks {
+		if tasks[i].StartTime.Before(now) {
 			http.Error(w, "A task cannot start before the current time", http.StatusBadRequest)
 			return
 		}
+		if tasks[i].ID == "" {
+			tasks[i].ID = tr.NewTaskID()
+		}
+		added[i] = tasks[i].ID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+		return
+	}
+	sched := s.Schedule
+	if dryRun {
+		sched = s.Schedule.Clone()
+	}
+	overflow, err := sched.UpdateTimeBlockWith(cs, tasks...)
+	if err != nil {
+		writeTaskError(w, err)
+		return
+	}
+	sched.UpdateCurrentTask()
+	if dryRun {
+		s.respondWithDiff(w, sched, overflow, added)
+		return
+	}
+	s.Changed()
+
+	err = tr.SendJson(NewOverflowModel(overflow), w)
+	if err != nil {
+		log.Printf("UpdateTasks: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) Undo(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+		return
+	}
+
+	err := s.Schedule.Undo()
+	if err != nil {
+		log.Printf("Undo: %s", err.Error())
+		if errors.As(err, &tr.EmptyJournalError{}) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Undo failed", http.StatusInternalServerError)
+		}
+		return
+	}
+	s.Schedule.UpdateCurrentTask()
+	s.Changed()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) Redo(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+		return
 	}
 
-	err = s.Schedule.UpdateTimeBlock(tasks...)
-	// TODO check type of error and return appropriate response
+	err := s.Schedule.Redo()
 	if err != nil {
-		http.Error(w, "Update failed", http.StatusInternalServerError)
+		log.Printf("Redo: %s", err.Error())
+		if errors.As(err, &tr.EmptyJournalError{}) {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Redo failed", http.StatusInternalServerError)
+		}
 		return
 	}
+	s.Schedule.UpdateCurrentTask()
+	s.Changed()
 
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) BuildSchedule(w http.ResponseWriter, r *http.Request) {
+// GetTask responds with the task with the ID in the path,
+// marked current if it is the task at the current time.
+func (s *Server) GetTask(w http.ResponseWriter, r *http.Request) {
 	// TODO authenticate
-	// TODO test
-	if s.Schedule != nil {
-		http.Error(w, "Today's schedule has already been built.", http.StatusBadRequest)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusNotFound)
 		return
 	}
-	err := r.ParseMultipartForm(8 << 20) // max file size 8 MB
+	task, idx := s.Schedule.Tasks.GetTaskByID(mux.Vars(r)["id"])
+	if task == nil {
+		http.Error(w, "No task found with the given ID.", http.StatusNotFound)
+		return
+	}
+	_, current := s.Schedule.Tasks.GetTaskAtTime(s.now())
+
+	err := tr.SendJson(NewScheduleTaskModel(task, idx == current), w)
 	if err != nil {
-		log.Printf("BuildSchedule: %s", err.Error())
+		log.Printf("GetTask: %s", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// PatchTask applies the changes in the request body to the task
+// with the ID in the path, and responds with the changed task.
+func (s *Server) PatchTask(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	var patch tr.TaskPatch
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		log.Printf("PatchTask: %s", err.Error())
+		http.Error(w, "Invalid HTTP Body", http.StatusBadRequest)
+		return
+	}
+	if patch.StartTime != nil && patch.StartTime.Before(s.now()) {
+		http.Error(w, "A task cannot start before the current time", http.StatusBadRequest)
 		return
 	}
 
-	buildFile, h, err := r.FormFile("buildFile")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+		return
+	}
+	id := mux.Vars(r)["id"]
+	err = s.Schedule.PatchTask(id, patch)
 	if err != nil {
-		log.Printf("BuildSchedule: %s", err.Error())
-		w.WriteHeader(http.StatusBadRequest)
+		log.Printf("PatchTask: %s", err.Error())
+		writeTaskError(w, err)
 		return
 	}
-	tmpfile, err := os.CreateTemp("./", h.Filename)
-	defer func() {
-		tmpfile.Close()
-		err = os.Remove(tmpfile.Name())
-		if err != nil {
-			log.Printf("BuildSchedule: %s", err.Error())
-		}
-	}()
+	s.Schedule.UpdateCurrentTask()
+	s.Changed()
+
+	task, idx := s.Schedule.Tasks.GetTaskByID(id)
+	err = tr.SendJson(NewScheduleTaskModel(task, idx == s.Schedule.CurrentID), w)
 	if err != nil {
-		log.Printf("BuildSchedule: %s", err.Error())
+		log.Printf("PatchTask: %s", err.Error())
 		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) DeleteTask(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Schedule == nil {
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
 		return
 	}
-	_, err = io.Copy(tmpfile, buildFile)
+
+	err := s.Schedule.RemoveTask(mux.Vars(r)["id"])
 	if err != nil {
-		log.Printf("BuildSchedule: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("DeleteTask: %s", err.Error())
+		writeTaskError(w, err)
 		return
 	}
+	s.Schedule.UpdateCurrentTask()
+	s.Changed()
 
-	s.Schedule, err = tr.BuildFromFile(tmpfile.Name())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTaskError responds with the status code that
+// matches an error returned when changing a task.
+func writeTaskError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.As(err, &tr.TaskNotFoundError{}):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.As(err, &tr.InvalidTimeError{}), errors.As(err, &tr.InvalidScheduleError{}):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.As(err, &tr.TimeConflictError{}):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Encountered an internal server error.", http.StatusInternalServerError)
+	}
+}
+
+// Ways that an uploaded file can be used to build the schedule.
+const (
+	buildNew     = ""        // Build a schedule if there isn't one yet
+	buildReplace = "replace" // Replace the schedule's tasks
+	buildMerge   = "merge"   // Fold the tasks into the schedule
+)
+
+// BuildSchedule builds today's schedule from an uploaded file.
+// With the query mode=merge, the file's tasks are merged into the
+// existing schedule instead, and with mode=replace they replace it.
+func (s *Server) BuildSchedule(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode != buildNew && mode != buildReplace && mode != buildMerge {
+		http.Error(w, "The mode must be replace or merge.", http.StatusBadRequest)
+		return
+	}
+	s.buildSchedule(w, r, mode)
+}
+
+// ReplaceSchedule replaces today's schedule, or builds it if
+// there isn't one yet, from an uploaded file.
+func (s *Server) ReplaceSchedule(w http.ResponseWriter, r *http.Request) {
+	s.buildSchedule(w, r, buildReplace)
+}
+
+// buildSchedule builds, replaces or merges into the schedule
+// according to mode, and responds with the resulting schedule.
+// With the query dry_run=true, the schedule is left unchanged.
+func (s *Server) buildSchedule(w http.ResponseWriter, r *http.Request, mode string) {
+	// TODO authenticate
+	dryRun, err := isDryRun(r)
+	if err != nil {
+		http.Error(w, "Invalid value for dry_run.", http.StatusBadRequest)
+		return
+	}
+
+	body, format, err := buildFile(w, r)
 	if err != nil {
 		log.Printf("BuildSchedule: %s", err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if format == "" {
+		http.Error(w, "Please send a csv, iCalendar or JSON file.", http.StatusUnsupportedMediaType)
+		return
+	}
+	tasks, err := tr.ParseTasks(body, format, tr.StartOfDay(s.today(), s.DayStart))
+	if err != nil {
+		writeBuildError(w, err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyTasks(w, tasks, mode, dryRun)
+}
+
+// isDryRun returns true if the request has the query dry_run=true.
+func isDryRun(r *http.Request) (bool, error) {
+	q := r.URL.Query().Get("dry_run")
+	if q == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(q)
+}
+
+// applyTasks builds, replaces or merges into today's schedule
+// with the given tasks according to mode, and responds with the
+// resulting schedule. If dryRun is true, the schedule is left
+// unchanged. The caller must hold s.mu.
+func (s *Server) applyTasks(w http.ResponseWriter, tasks []tr.Task, mode string, dryRun bool) {
+	var sched *tr.Schedule
+	var err error
+	switch {
+	case s.Schedule == nil && mode == buildMerge:
+		http.Error(w, "No schedule has been built yet.", http.StatusBadRequest)
+		return
+	case s.Schedule != nil && mode == buildNew:
+		http.Error(w, "Today's schedule has already been built. Use PUT /v1/schedule/tasks or mode=replace to replace it, or mode=merge to merge into it.", http.StatusBadRequest)
+		return
+	case s.Schedule == nil:
+		sched = s.newSchedule(tr.TaskList{}, s.today())
+		err = sched.Replace(tasks...)
+	case mode == buildReplace:
+		sched = s.Schedule.Clone()
+		err = sched.Replace(tasks...)
+	default:
+		sched = s.Schedule.Clone()
+		err = sched.UpdateTimeBlock(tasks...)
+	}
+	if err != nil {
+		writeBuildError(w, err)
 		return
 	}
-	current, idx := s.Schedule.Tasks.GetTaskAtTime(time.Now())
-	if s.Ntfy != "" && idx != -1 {
-		currentModel := TaskModel{
-			current.Description,
-			current.Tag,
-			current.EndTime.Format(time.TimeOnly),
+	sched.UpdateCurrentTask()
+
+	if !dryRun {
+		var previous *tr.Task
+		if s.Schedule != nil {
+			previous = s.Schedule.CurrentTask
 		}
-		err = s.NtfyNewCurrent(s.Ntfy, currentModel)
-		if err != nil {
-			log.Printf("GetSchedule: %s", err.Error())
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		s.Schedule = sched
+		s.Changed()
+		if sched.CurrentTask != nil && !sameTask(previous, sched.CurrentTask) {
+			s.EmitCurrent(NewTaskModel(sched.CurrentTask))
 		}
-		log.Println("ntfy sent")
 	}
 
-	w.WriteHeader(http.StatusOK)
+	err = tr.SendJson(NewScheduleModel(sched), w)
+	if err != nil {
+		log.Printf("applyTasks: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// writeBuildError responds with the reason a schedule
+// couldn't be built from a file.
+func writeBuildError(w http.ResponseWriter, err error) {
+	var csvErr tr.CSVError
+	var listErr tr.TaskListError
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &csvErr):
+		http.Error(w, csvErr.Error(), http.StatusBadRequest)
+	case errors.As(err, &listErr):
+		writeTaskListError(w, listErr)
+	case errors.As(err, &sizeErr):
+		http.Error(w, "The file is too large.", http.StatusRequestEntityTooLarge)
+	default:
+		log.Printf("BuildSchedule: %s", err.Error())
+		http.Error(w, "Could not build a schedule from the file.", http.StatusBadRequest)
+	}
 }
 
-func (s *Server) PlanSchedule(w http.ResponseWriter, r *http.Request) {
-	// TODO implement
+// TaskErrorModel is the JSON representation of a task
+// that was rejected when building a schedule.
+type TaskErrorModel struct {
+	Index       int    `json:"index"`
+	Description string `json:"description"`
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Kind        string `json:"kind"`
+	Error       string `json:"error"`
+}
+
+// writeTaskListError responds with a list of the tasks that
+// kept a schedule from being built, and why. Each task's kind
+// is "conflict" if it overlaps another task, or "invalid_time".
+func writeTaskListError(w http.ResponseWriter, listErr tr.TaskListError) {
+	model := struct {
+		Errors []TaskErrorModel `json:"errors"`
+	}{make([]TaskErrorModel, len(listErr.Tasks))}
+	for i, e := range listErr.Tasks {
+		kind := "invalid_time"
+		if errors.As(e.Err, &tr.TimeConflictError{}) {
+			kind = "conflict"
+		}
+		model.Errors[i] = TaskErrorModel{
+			Index:       e.Index,
+			Description: e.Task.Description,
+			Start:       e.Task.StartTime.Format(time.RFC3339),
+			End:         e.Task.EndTime.Format(time.RFC3339),
+			Kind:        kind,
+			Error:       e.Err.Error(),
+		}
+	}
+	msg, err := json.Marshal(model)
+	if err != nil {
+		log.Printf("writeTaskListError: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	w.Write(msg)
 }
 
-func (s *Server) NtfyNewCurrent(ntfyId string, task TaskModel) error {
-	// TODO implement
-	req, err := http.NewRequest("POST", "https://ntfy.sh/"+ntfyId,
-		strings.NewReader("Until "+task.Until))
+// maxBuildFileSize is the largest file a schedule can be built from.
+const maxBuildFileSize = 8 << 20 // 8 MB
+
+// buildFile returns the file a schedule should be built from and
+// its format. The file is either uploaded as the buildFile field of
+// a multipart form, or sent as the request body with a Content-Type
+// of text/csv, text/calendar or application/json. The format is
+// empty if the file's type isn't supported.
+func buildFile(w http.ResponseWriter, r *http.Request) (io.Reader, tr.Format, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBuildFileSize)
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 	if err != nil {
-		return err
+		return nil, "", fmt.Errorf("buildFile: %w", err)
+	}
+	if mediaType != "multipart/form-data" {
+		format, _ := tr.FormatFromMediaType(mediaType)
+		return r.Body, format, nil
 	}
-	req.Header.Set("Title", task.Description)
-	req.Header.Set("Tags", "hourglass")
-	_, err = http.DefaultClient.Do(req)
 
-	return err
+	// Parts are read as a stream, so large uploads are
+	// never spooled to disk.
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, "", fmt.Errorf("buildFile: %w", err)
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, "", errors.New("buildFile: no buildFile in form")
+		} else if err != nil {
+			return nil, "", fmt.Errorf("buildFile: %w", err)
+		}
+		if part.FormName() != "buildFile" {
+			continue
+		}
+		format, ok := tr.FormatFromMediaType(part.Header.Get("Content-Type"))
+		if !ok {
+			format = tr.FormatFromFileName(part.FileName())
+		}
+		return part, format, nil
+	}
+}
+
+// clock returns the server's clock.
+func (s *Server) clock() tr.Clock {
+	if s.Clock == nil {
+		return tr.SystemClock{}
+	}
+	return s.Clock
 }
 
-func (s *Server) NtfyNewSchedule() error {
-	// TODO implement
-	return nil
+// now returns the current time according to the server's clock.
+func (s *Server) now() time.Time {
+	return s.clock().Now()
 }
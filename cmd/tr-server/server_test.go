@@ -0,0 +1,740 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+// newTestServer returns a Server with a fake clock set to noon
+// and a schedule of two tasks, the first of which is running.
+func newTestServer(t *testing.T) (*Server, *tr.FakeClock) {
+	c := tr.NewFakeClock(time.Date(2024, time.March, 10, 12, 0, 0, 0, time.Local))
+	now := c.Now()
+	tl, err := tr.NewTaskList(
+		tr.NewTask("Task 1", now.Add(-30*time.Minute), now.Add(30*time.Minute)),
+		tr.NewTask("Task 2", now.Add(40*time.Minute), now.Add(90*time.Minute)),
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sched := tr.NewSchedule(tl, tr.ScheduleOptions{Clock: c})
+	return &Server{
+		StateDir: t.TempDir(),
+		Clock:    c,
+		Schedule: &sched,
+	}, c
+}
+
+// serve sends a request to router with the given content type,
+// if any, and body, and returns the response.
+func serve(t *testing.T, router http.Handler, method, target, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// descriptions returns the descriptions of the tasks in the
+// schedule that w responded with, breaks included.
+func descriptions(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var model ScheduleModel
+	err := json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var desc []string
+	for _, task := range model.Tasks {
+		desc = append(desc, task.Description)
+	}
+	return fmt.Sprint(desc)
+}
+
+func TestGetSchedule(t *testing.T) {
+	s, _ := newTestServer(t)
+	router := s.Routes()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/schedule", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	var model ScheduleModel
+	err := json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(model.Tasks) != 3 {
+		t.Fatalf("Expected 3 tasks, got %d", len(model.Tasks))
+	}
+	if !model.Tasks[0].IsCurrent || model.Tasks[1].IsCurrent || !model.Tasks[1].IsBreak {
+		t.Fatalf("Expected first task to be current and second to be a break, got: %+v", model.Tasks)
+	}
+	_, err = time.Parse(time.RFC3339, model.Tasks[0].Start)
+	if err != nil {
+		t.Fatalf("Expected RFC 3339 start time: %s", err.Error())
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/schedule", nil)
+	req.Header.Set("Accept", "text/plain")
+	router.ServeHTTP(w, req)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Fatalf("Expected text/plain response, got %s", w.Header().Get("Content-Type"))
+	}
+	if w.Body.String() != s.Schedule.String() {
+		t.Fatalf("Expected: %s\n Got: %s", s.Schedule.String(), w.Body.String())
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s, _ := newTestServer(t)
+	router := s.Routes()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/v1/schedule", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("Expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/get", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Header().Get("Deprecation") == "" {
+		t.Fatalf("Expected legacy route to set a Deprecation header")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/schedule", nil))
+	if w.Header().Get("Deprecation") != "" {
+		t.Fatalf("Versioned route should not set a Deprecation header")
+	}
+
+	// Routes added after versioning have no unversioned alias
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/undo", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestCurrentTaskTransition(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+
+	current := func() string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/current", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var body map[string]TaskModel
+		err := json.NewDecoder(w.Body).Decode(&body)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return body["Task"].Description
+	}
+
+	if desc := current(); desc != "Task 1" {
+		t.Fatalf("Expected Task 1, got %s", desc)
+	}
+	c.Advance(35 * time.Minute)
+	if desc := current(); desc != "Break" {
+		t.Fatalf("Expected Break, got %s", desc)
+	}
+
+	// Reading the schedule marks the task at the current time, but
+	// leaves the current task to be changed by the scheduler
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/v1/schedule", nil))
+	var model ScheduleModel
+	err := json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if model.Tasks[0].IsCurrent || !model.Tasks[1].IsCurrent {
+		t.Fatalf("Expected the break to be marked current, got: %+v", model.Tasks)
+	}
+	if s.Schedule.CurrentTask == nil || s.Schedule.CurrentTask.Description != "Task 1" {
+		t.Fatalf("Expected reads not to change the current task, got %+v", s.Schedule.CurrentTask)
+	}
+	c.Advance(10 * time.Minute)
+	s.CheckCurrentTask()
+	if s.Schedule.CurrentTask.Description != "Task 2" {
+		t.Fatalf("Expected Task 2, got %s", s.Schedule.CurrentTask.Description)
+	}
+}
+
+func TestConcurrentRequests(t *testing.T) {
+	s, _ := newTestServer(t)
+	router := s.Routes()
+	id := s.Schedule.Tasks[2].ID
+	later := s.now().Add(2 * time.Hour)
+
+	requests := []func() *http.Request{
+		func() *http.Request { return httptest.NewRequest("GET", "/v1/schedule", nil) },
+		func() *http.Request { return httptest.NewRequest("GET", "/v1/current", nil) },
+		func() *http.Request { return httptest.NewRequest("GET", "/v1/tasks/"+id, nil) },
+		func() *http.Request {
+			body := fmt.Sprintf(`{"Description":"Interruption","Tag":"","Until":"%s"}`,
+				s.now().Add(20*time.Minute).Format(time.TimeOnly))
+			return httptest.NewRequest("POST", "/v1/current", strings.NewReader(body))
+		},
+		func() *http.Request {
+			task := tr.NewTask("Later", later, later.Add(15*time.Minute))
+			body, _ := json.Marshal([]tr.Task{task})
+			return httptest.NewRequest("PUT", "/v1/schedule", strings.NewReader(string(body)))
+		},
+		func() *http.Request { return httptest.NewRequest("POST", "/v1/undo", nil) },
+		func() *http.Request { return httptest.NewRequest("POST", "/v1/redo", nil) },
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, newRequest := range requests {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, newRequest())
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			s.CheckCurrentTask()
+		}
+	}()
+	wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.Schedule.Tasks.IsConsistent() {
+		t.Fatalf("Schedule is inconsistent after concurrent requests:\n%s", s.Schedule.String())
+	}
+}
+
+func TestGetScheduleICS(t *testing.T) {
+	s, _ := newTestServer(t)
+	router := s.Routes()
+
+	for _, path := range []string{"/schedule.ics", "/v1/schedule.ics"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d, got %d", path, http.StatusOK, w.Code)
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/calendar") {
+			t.Fatalf("%s: expected text/calendar response, got %s", path, w.Header().Get("Content-Type"))
+		}
+		if strings.Count(w.Body.String(), "BEGIN:VEVENT") != 3 {
+			t.Fatalf("%s: expected 3 events, got:\n%s", path, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/schedule.ics?breaks=false", nil))
+	if strings.Count(w.Body.String(), "BEGIN:VEVENT") != 2 || strings.Contains(w.Body.String(), "SUMMARY:Break") {
+		t.Fatalf("Expected 2 events without breaks, got:\n%s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/schedule.ics?breaks=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestBuildSchedule(t *testing.T) {
+	csvFile := "Eat Lunch,12:15,12:45,food\nEat Dinner,17:00,18:00,food\n"
+
+	multipartBody := func() (*bytes.Buffer, string) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, err := mw.CreateFormFile("buildFile", "today.csv")
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		io.WriteString(fw, csvFile)
+		mw.Close()
+		return &buf, mw.FormDataContentType()
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        io.Reader
+		code        int
+	}{
+		{"csv", "text/csv", strings.NewReader(csvFile), http.StatusOK},
+		{"csv with charset", "text/csv; charset=utf-8", strings.NewReader(csvFile), http.StatusOK},
+		{"json", "application/json", strings.NewReader(`[
+			{"Description": "Eat Lunch", "Start": "2024-03-10T12:15:00Z", "End": "2024-03-10T12:45:00Z", "Tag": "food"},
+			{"Description": "Eat Dinner", "Start": "2024-03-10T17:00:00Z", "End": "2024-03-10T18:00:00Z", "Tag": "food"}
+		]`), http.StatusOK},
+		{"calendar", "text/calendar", strings.NewReader("BEGIN:VCALENDAR\r\n" +
+			"BEGIN:VEVENT\r\nSUMMARY:Eat Lunch\r\nDTSTART:20240310T121500\r\nDTEND:20240310T124500\r\nEND:VEVENT\r\n" +
+			"BEGIN:VEVENT\r\nSUMMARY:Eat Dinner\r\nDTSTART:20240310T170000\r\nDTEND:20240310T180000\r\nEND:VEVENT\r\n" +
+			"END:VCALENDAR\r\n"), http.StatusOK},
+		{"multipart", "", nil, http.StatusOK},
+		{"bad csv", "text/csv", strings.NewReader("Eat Lunch,12:15\n"), http.StatusBadRequest},
+		{"unsupported", "application/pdf", strings.NewReader("%PDF"), http.StatusUnsupportedMediaType},
+	}
+	for _, test := range tests {
+		s, _ := newTestServer(t)
+		s.Schedule = nil
+		body, contentType := test.body, test.contentType
+		if body == nil {
+			body, contentType = multipartBody()
+		}
+		req := httptest.NewRequest("POST", "/v1/schedule", body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		s.Routes().ServeHTTP(w, req)
+		if w.Code != test.code {
+			t.Fatalf("%s: expected status %d, got %d: %s", test.name, test.code, w.Code, w.Body.String())
+		}
+		if test.code != http.StatusOK {
+			continue
+		}
+		if s.Schedule == nil || len(s.Schedule.Tasks) != 3 {
+			t.Fatalf("%s: expected a schedule of 3 tasks, got: %v", test.name, s.Schedule)
+		}
+	}
+}
+
+func TestBuildScheduleTaskErrors(t *testing.T) {
+	s, _ := newTestServer(t)
+	s.Schedule = nil
+
+	req := httptest.NewRequest("POST", "/v1/schedule", strings.NewReader(`[
+		{"Description": "Eat Lunch", "Start": "2024-03-10T12:15:00Z", "End": "2024-03-10T12:45:00Z"},
+		{"Description": "Call", "Start": "2024-03-10T12:30:00Z", "End": "2024-03-10T13:00:00Z"},
+		{"Description": "Stretch", "Start": "2024-03-10T14:00:00Z", "End": "2024-03-10T14:03:00Z"}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	var model struct {
+		Errors []TaskErrorModel `json:"errors"`
+	}
+	err := json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(model.Errors) != 1 || model.Errors[0].Index != 2 || model.Errors[0].Kind != "invalid_time" {
+		t.Fatalf("Expected task 2 to be too short, got: %+v", model.Errors)
+	}
+
+	req = httptest.NewRequest("POST", "/v1/schedule", strings.NewReader(`[
+		{"Description": "Eat Lunch", "Start": "2024-03-10T12:15:00Z", "End": "2024-03-10T12:45:00Z"},
+		{"Description": "Call", "Start": "2024-03-10T12:30:00Z", "End": "2024-03-10T13:00:00Z"}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, req)
+	err = json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(model.Errors) != 1 || model.Errors[0].Index != 1 || model.Errors[0].Kind != "conflict" {
+		t.Fatalf("Expected task 1 to conflict, got: %+v", model.Errors)
+	}
+
+	// Tasks on another day are rejected, as they are by a replace or an update
+	req = httptest.NewRequest("POST", "/v1/schedule", strings.NewReader(`[
+		{"Description": "Eat Lunch", "Start": "2024-03-10T12:15:00Z", "End": "2024-03-10T12:45:00Z"},
+		{"Description": "Tomorrow", "Start": "2024-03-11T12:00:00Z", "End": "2024-03-11T13:00:00Z"}
+	]`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	s.Routes().ServeHTTP(w, req)
+	err = json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(model.Errors) != 1 || model.Errors[0].Index != 1 || model.Errors[0].Kind != "invalid_time" {
+		t.Fatalf("Expected task 1 to be outside the day, got: %+v", model.Errors)
+	}
+	if s.Schedule != nil {
+		t.Fatalf("Expected no schedule to be built")
+	}
+}
+
+func TestReplaceAndMergeSchedule(t *testing.T) {
+	s, _ := newTestServer(t)
+	router := s.Routes()
+	scheduled := func() []string {
+		var desc []string
+		for _, t := range s.Schedule.Tasks.WithoutBreaks() {
+			desc = append(desc, t.Description)
+		}
+		return desc
+	}
+
+	w := serve(t, router, "POST", "/v1/schedule", "text/csv", "New,14:00,15:00\n")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = serve(t, router, "PUT", "/v1/schedule/tasks?dry_run=true", "text/csv", "New,14:00,15:00\n")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var model ScheduleModel
+	err := json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(model.Tasks) != 1 || model.Tasks[0].Description != "New" {
+		t.Fatalf("Expected dry run to return only \"New\", got: %+v", model.Tasks)
+	}
+	if got := fmt.Sprint(scheduled()); got != "[Task 1 Task 2]" {
+		t.Fatalf("Expected dry run to leave the schedule unchanged, got: %s", got)
+	}
+
+	w = serve(t, router, "POST", "/v1/schedule?mode=merge", "text/csv", "Merged,13:30,14:00\n")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := fmt.Sprint(scheduled()); got != "[Task 1 Task 2 Merged]" {
+		t.Fatalf("Expected merged task to be added, got: %s", got)
+	}
+
+	// A PUT to the schedule itself updates it, as it always has
+	now := s.now()
+	body, _ := json.Marshal([]tr.Task{tr.NewTask("Updated", now.Add(3*time.Hour), now.Add(4*time.Hour))})
+	w = serve(t, router, "PUT", "/v1/schedule", "application/json", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := fmt.Sprint(scheduled()); got != "[Task 1 Task 2 Merged Updated]" {
+		t.Fatalf("Expected the update to keep the other tasks, got: %s", got)
+	}
+
+	w = serve(t, router, "PUT", "/v1/schedule/tasks", "text/csv", "Replaced,13:00,14:00\n")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := fmt.Sprint(scheduled()); got != "[Replaced]" || s.Schedule.CurrentTask != nil {
+		t.Fatalf("Expected only \"Replaced\" with no current task, got: %s", got)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/undo", nil))
+	if got := fmt.Sprint(scheduled()); got != "[Task 1 Task 2 Merged Updated]" {
+		t.Fatalf("Expected undo to restore the updated schedule, got: %s", got)
+	}
+
+	w = serve(t, router, "POST", "/v1/schedule?mode=append", "text/csv", "New,14:00,15:00\n")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestUpdateTasksShiftLater(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	send := func(target string, tasks ...tr.Task) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(tasks)
+		return serve(t, router, "PUT", target, "", string(body))
+	}
+	summary := func() string {
+		var desc []string
+		for _, t := range s.Schedule.Tasks.WithoutBreaks() {
+			desc = append(desc, t.Description+" "+t.StartTime.Format("15:04"))
+		}
+		return fmt.Sprint(desc)
+	}
+	now := c.Now()
+
+	// Task 1 is interrupted and finished after the call, and Task 2 is moved back
+	w := send("/v1/schedule", tr.NewTask("Call", now, now.Add(30*time.Minute)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := summary(); got != "[Task 1 11:30 Call 12:00 Task 1 12:30 Task 2 13:00]" {
+		t.Fatalf("Unexpected schedule: %s", got)
+	}
+	if !strings.Contains(w.Body.String(), `"overflow":[]`) {
+		t.Fatalf("Expected no overflow, got %s", w.Body.String())
+	}
+
+	// Fixed tasks aren't moved
+	meeting := tr.NewTask("Meeting", now.Add(2*time.Hour), now.Add(3*time.Hour)).AsFixed(true)
+	send("/v1/schedule", meeting)
+	w = send("/v1/schedule", tr.NewTask("Email", now.Add(150*time.Minute), now.Add(200*time.Minute)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d for a task overlapping a fixed task, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Tasks pushed out of the day are reported
+	day := tr.DayOf(now, 0)
+	late := tr.NewTask("Late", day.Add(23*time.Hour), day.Add(23*time.Hour+30*time.Minute))
+	send("/v1/schedule", late)
+	w = send("/update", tr.NewTask("Evening", day.Add(22*time.Hour), day.Add(23*time.Hour+45*time.Minute)))
+	var model OverflowModel
+	err := json.NewDecoder(w.Body).Decode(&model)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(model.Overflow) != 1 || model.Overflow[0].Description != "Late" {
+		t.Fatalf("Expected Late to be pushed out of the day, got %+v", model.Overflow)
+	}
+}
+
+func TestUpdateTasksStrategy(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	send := func(target string, tasks ...tr.Task) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(tasks)
+		return serve(t, router, "PUT", target, "", string(body))
+	}
+	summary := func() string {
+		var desc []string
+		for _, t := range s.Schedule.Tasks.WithoutBreaks() {
+			desc = append(desc, t.Description+" "+t.StartTime.Format("15:04"))
+		}
+		return fmt.Sprint(desc)
+	}
+	now := c.Now()
+
+	w := send("/v1/schedule?strategy=squash", tr.NewTask("Call", now, now.Add(30*time.Minute)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an unknown strategy, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = send("/v1/schedule?strategy=reject", tr.NewTask("Call", now, now.Add(30*time.Minute)))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d for a rejected conflict, got %d", http.StatusConflict, w.Code)
+	}
+
+	// Task 1 is cut short by the call, and Task 2 stays where it is
+	w = send("/update?strategy=split", tr.NewTask("Call", now, now.Add(30*time.Minute)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := summary(); got != "[Task 1 11:30 Call 12:00 Task 2 12:40]" {
+		t.Fatalf("Unexpected schedule: %s", got)
+	}
+}
+
+func TestDryRunDiff(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	send := func(method, target string, body []byte) DiffModel {
+		w := serve(t, router, method, target, "", string(body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var model DiffModel
+		err := json.NewDecoder(w.Body).Decode(&model)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return model
+	}
+	kinds := func(model DiffModel) string {
+		var k []string
+		for _, c := range model.Changes {
+			k = append(k, c.Kind)
+		}
+		return fmt.Sprint(k)
+	}
+	before := s.Schedule.Tasks.String()
+	now := c.Now()
+
+	body, _ := json.Marshal([]tr.Task{tr.NewTask("Call", now.Add(10*time.Minute), now.Add(20*time.Minute))})
+	model := send("PUT", "/v1/schedule?dry_run=true", body)
+	if got := kinds(model); got != "[split added]" {
+		t.Fatalf("Expected Task 1 to be split by the call, got %s: %+v", got, model.Changes)
+	}
+	if len(model.Changes[0].After) != 2 || len(model.Schedule.Tasks) != 4 {
+		t.Fatalf("Expected the proposed schedule to have both parts of Task 1, got %+v", model)
+	}
+
+	body, _ = json.Marshal(TaskModel{Description: "Email", Until: now.Add(50 * time.Minute).Format(time.TimeOnly)})
+	model = send("POST", "/v1/current?dry_run=true", body)
+	if got := kinds(model); got != "[truncated added truncated]" {
+		t.Fatalf("Expected Task 1 and Task 2 to be cut short, got %s: %+v", got, model.Changes)
+	}
+
+	if s.Schedule.Tasks.String() != before || s.Schedule.Undo() == nil {
+		t.Fatalf("Expected a dry run to leave the schedule unchanged, got %v", s.Schedule.Tasks)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/v1/current?dry_run=maybe", bytes.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an invalid dry_run, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestChangeCurrentTaskUntil(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	send := func(until string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(TaskModel{Description: "Email", Until: until})
+		return serve(t, router, "POST", "/v1/current", "", string(body))
+	}
+
+	w := send("soon")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), time.TimeOnly) {
+		t.Fatalf("Expected status %d asking for the time format, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	// Days begin at midnight, so an earlier time isn't taken to be tomorrow
+	w = send("11:00:00")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "before the current time") {
+		t.Fatalf("Expected status %d for a time that has passed, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	// When days begin at 04:00, times before then are after midnight
+	s.DayStart = 4 * time.Hour
+	s.Schedule.DayStart = 4 * time.Hour
+	c.Set(time.Date(2024, time.March, 10, 23, 0, 0, 0, time.Local))
+	w = send("01:00:00")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if end := s.Schedule.CurrentTask.EndTime; end.Format(time.DateTime) != "2024-03-11 01:00:00" {
+		t.Fatalf("Expected the task to end after midnight, got %v", end)
+	}
+	w = send("05:00:00")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "before the current time") {
+		t.Fatalf("Expected status %d for a time outside the day, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	tasks := func(s *Server) string {
+		var desc []string
+		for _, t := range s.Schedule.Tasks.WithoutBreaks() {
+			desc = append(desc, t.Description)
+		}
+		return fmt.Sprint(desc)
+	}
+
+	w := serve(t, router, "POST", "/v1/undo", "", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d with nothing to undo, got %d", http.StatusConflict, w.Code)
+	}
+	w = serve(t, router, "POST", "/v1/redo", "", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d with nothing to redo, got %d", http.StatusConflict, w.Code)
+	}
+
+	now := c.Now()
+	body, _ := json.Marshal([]tr.Task{tr.NewTask("Call", now.Add(2*time.Hour), now.Add(3*time.Hour))})
+	w = serve(t, router, "PUT", "/v1/schedule", "application/json", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// The history survives a restart
+	restarted := &Server{StateDir: s.StateDir, Clock: c}
+	err := restarted.LoadSchedule()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	router = restarted.Routes()
+	w = serve(t, router, "POST", "/v1/undo", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := tasks(restarted); got != "[Task 1 Task 2]" {
+		t.Fatalf("Expected the call to be undone, got %s", got)
+	}
+	w = serve(t, router, "POST", "/v1/redo", "", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got := tasks(restarted); got != "[Task 1 Task 2 Call]" {
+		t.Fatalf("Expected the call to be redone, got %s", got)
+	}
+	w = serve(t, router, "POST", "/v1/redo", "", "")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d with nothing left to redo, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestTaskEndpoints(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+	now := c.Now()
+	first, second := s.Schedule.Tasks[0], s.Schedule.Tasks[2]
+	decode := func(w *httptest.ResponseRecorder) ScheduleTaskModel {
+		t.Helper()
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var model ScheduleTaskModel
+		err := json.NewDecoder(w.Body).Decode(&model)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		return model
+	}
+
+	model := decode(serve(t, router, "GET", "/v1/tasks/"+first.ID, "", ""))
+	if model.ID != first.ID || model.Description != "Task 1" || !model.IsCurrent {
+		t.Fatalf("Expected Task 1 to be current, got %+v", model)
+	}
+	model = decode(serve(t, router, "GET", "/v1/tasks/"+second.ID, "", ""))
+	if model.Description != "Task 2" || model.IsCurrent {
+		t.Fatalf("Expected Task 2 not to be current, got %+v", model)
+	}
+
+	for _, method := range []string{"GET", "PATCH", "DELETE"} {
+		w := serve(t, router, method, "/v1/tasks/nope", "application/json", "{}")
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("Expected status %d for %s of an unknown task, got %d", http.StatusNotFound, method, w.Code)
+		}
+	}
+
+	w := serve(t, router, "PATCH", "/v1/tasks/"+first.ID, "application/json", "not json")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for an invalid body, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	model = decode(serve(t, router, "PATCH", "/v1/tasks/"+first.ID, "application/json", `{"Fixed": true}`))
+	if model.ID != first.ID || !model.IsFixed || !model.IsCurrent {
+		t.Fatalf("Expected Task 1 to be fixed and current, got %+v", model)
+	}
+
+	// Task 2 can't be moved over the now fixed Task 1
+	body, _ := json.Marshal(map[string]time.Time{
+		"Start": now.Add(10 * time.Minute),
+		"End":   now.Add(40 * time.Minute),
+	})
+	w = serve(t, router, "PATCH", "/v1/tasks/"+second.ID, "application/json", string(body))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status %d for a conflicting patch, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if got := s.Schedule.Tasks.WithoutBreaks(); len(got) != 2 || !got[1].StartTime.Equal(now.Add(40*time.Minute)) {
+		t.Fatalf("Expected the schedule to be unchanged, got %v", got)
+	}
+}
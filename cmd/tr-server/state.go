@@ -0,0 +1,70 @@
+package main
+
+// This includes the code used to persist the live schedule
+// so that it survives a crash or restart of the server.
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	tr "github.com/dethancosta/timeruler/internal"
+	gap "github.com/muesli/go-app-paths"
+)
+
+const scheduleFileName = "schedule.json"
+
+// DefaultStateDir returns the directory that holds timeruler's
+// config file, which is where state is saved unless otherwise given.
+func DefaultStateDir() (string, error) {
+	scope := gap.NewScope(gap.User, "timeruler")
+	return scope.ConfigPath("")
+}
+
+// SaveSchedule writes the live schedule to the server's state
+// directory. Failures are logged rather than returned, since the
+// in-memory schedule is still usable.
+func (s *Server) SaveSchedule() {
+	if s.StateDir == "" || s.Schedule == nil {
+		return
+	}
+	err := os.MkdirAll(s.StateDir, os.ModePerm)
+	if err != nil {
+		log.Printf("SaveSchedule: %s", err.Error())
+		return
+	}
+	err = s.Schedule.SaveToFile(filepath.Join(s.StateDir, scheduleFileName), s.Schedule.Day)
+	if err != nil {
+		log.Printf("SaveSchedule: %s", err.Error())
+	}
+}
+
+// LoadSchedule restores the live schedule from the server's state
+// directory. A missing schedule file is not an error. A schedule
+// saved on an earlier day is restored too, so that RollOver can
+// archive it.
+func (s *Server) LoadSchedule() error {
+	if s.StateDir == "" {
+		return nil
+	}
+	sched, err := tr.ReadFromFile(filepath.Join(s.StateDir, scheduleFileName), s.now().Location(), s.DayStart)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if sched.Day.After(s.today()) {
+		return nil // Saved with a clock that was ahead, so ignore it
+	}
+	sched.Clock = s.clock()
+	err = sched.UpdateCurrentTask()
+	if err != nil {
+		// Not an error, there is just nothing scheduled right now
+		log.Printf("LoadSchedule: no current task")
+	}
+	s.Schedule = sched
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRestoreSchedule(t *testing.T) {
+	s, c := newTestServer(t)
+	s.SaveSchedule()
+
+	// A server started over the same state directory picks up the schedule
+	restored := &Server{StateDir: s.StateDir, Clock: c}
+	err := restored.LoadSchedule()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if restored.Schedule == nil || len(restored.Schedule.Tasks) != len(s.Schedule.Tasks) {
+		t.Fatalf("Expected the saved schedule to be restored, got %v", restored.Schedule)
+	}
+	for i, task := range s.Schedule.Tasks {
+		got := restored.Schedule.Tasks[i]
+		if !sameTask(got, task) || got.Description != task.Description || got.Tag != task.Tag {
+			t.Fatalf("Expected task %d to be %v, got %v", i, task, got)
+		}
+	}
+	if !sameTask(restored.Schedule.CurrentTask, s.Schedule.CurrentTask) {
+		t.Fatalf("Expected Task 1 to be current, got %v", restored.Schedule.CurrentTask)
+	}
+
+	// A schedule saved on an earlier day isn't used for today, but archived at start up
+	c.Set(time.Date(2024, time.March, 11, 9, 0, 0, 0, time.Local))
+	later := &Server{StateDir: s.StateDir, Clock: c}
+	err = later.LoadSchedule()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	later.RollOver()
+	if later.Schedule != nil {
+		t.Fatalf("Expected no schedule for the next day, got %s", later.Schedule.String())
+	}
+	if _, err := os.Stat(filepath.Join(s.StateDir, scheduleFileName)); !os.IsNotExist(err) {
+		t.Fatalf("Expected the saved schedule to be moved out of the state directory")
+	}
+	if _, err := os.Stat(filepath.Join(s.StateDir, archiveDirName, "2024-03-10.json")); err != nil {
+		t.Fatalf("Expected the saved schedule to be archived: %s", err.Error())
+	}
+}
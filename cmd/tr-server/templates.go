@@ -0,0 +1,267 @@
+package main
+
+// This includes the handlers for templates, which are named sets
+// of recurring tasks kept in the state directory. A template can
+// be applied to today's schedule or to a plan for a later day,
+// and the server can build each new day from one (see RollOver).
+// Once applied, the schedule is edited like any other; changes to
+// it don't affect the template.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+	"github.com/gorilla/mux"
+)
+
+const templatesDirName = "templates"
+
+// templateName matches the names that templates can be given.
+var templateName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// templateFile returns the file that the template with the
+// given name is kept in.
+func (s *Server) templateFile(name string) string {
+	return filepath.Join(s.StateDir, templatesDirName, name+".json")
+}
+
+// loadTemplate returns the template with the given name, or
+// nil if there is none. The caller must hold s.mu.
+func (s *Server) loadTemplate(name string) (*tr.Template, error) {
+	if s.StateDir == "" || !templateName.MatchString(name) {
+		return nil, nil
+	}
+	f, err := os.Open(s.templateFile(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("loadTemplate: %w", err)
+	}
+	defer f.Close()
+
+	var tmpl tr.Template
+	err = json.NewDecoder(f).Decode(&tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("loadTemplate: %s: %w", name, err)
+	}
+	tmpl.Name = name
+	return &tmpl, nil
+}
+
+// saveTemplate writes the template to the templates
+// directory. The caller must hold s.mu.
+func (s *Server) saveTemplate(tmpl tr.Template) error {
+	if s.StateDir == "" {
+		return errors.New("saveTemplate: no state directory")
+	}
+	err := os.MkdirAll(filepath.Join(s.StateDir, templatesDirName), os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("saveTemplate: %w", err)
+	}
+	payload, err := json.MarshalIndent(tmpl, "", "\t")
+	if err != nil {
+		return fmt.Errorf("saveTemplate: %w", err)
+	}
+	err = tr.WriteFileAtomic(s.templateFile(tmpl.Name), payload, 0644)
+	if err != nil {
+		return fmt.Errorf("saveTemplate: %w", err)
+	}
+	return nil
+}
+
+// templateSchedule builds the schedule for the given day from
+// the template, or returns nil if none of its tasks happen on
+// that day.
+func (s *Server) templateSchedule(tmpl *tr.Template, day time.Time) (*tr.Schedule, error) {
+	tasks, err := tmpl.TasksOn(tr.StartOfDay(day, s.DayStart))
+	if err != nil {
+		return nil, fmt.Errorf("templateSchedule: %w", err)
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	tl, err := tr.NewTaskList(tasks...)
+	if err != nil {
+		return nil, fmt.Errorf("templateSchedule: %s: %w", tmpl.Name, err)
+	}
+	return s.newSchedule(tl, day), nil
+}
+
+// GetTemplates lists the names of the stored templates.
+func (s *Server) GetTemplates(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := []string{}
+	entries, err := os.ReadDir(filepath.Join(s.StateDir, templatesDirName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("GetTemplates: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".json")
+		if filepath.Ext(e.Name()) == ".json" && templateName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	err = tr.SendJson(map[string][]string{"templates": names}, w)
+	if err != nil {
+		log.Printf("GetTemplates: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetTemplate responds with a stored template.
+func (s *Server) GetTemplate(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmpl, err := s.loadTemplate(mux.Vars(r)["name"])
+	if err != nil {
+		log.Printf("GetTemplate: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if tmpl == nil {
+		http.Error(w, "No template has that name.", http.StatusNotFound)
+		return
+	}
+	err = tr.SendJson(tmpl, w)
+	if err != nil {
+		log.Printf("GetTemplate: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// PutTemplate creates or replaces a template from the JSON in
+// the request body. The template takes its name from the URL.
+func (s *Server) PutTemplate(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	name := mux.Vars(r)["name"]
+	if !templateName.MatchString(name) {
+		http.Error(w, "Template names may only have letters, digits, - and _.", http.StatusBadRequest)
+		return
+	}
+	var tmpl tr.Template
+	err := json.NewDecoder(r.Body).Decode(&tmpl)
+	if err != nil {
+		log.Printf("PutTemplate: %s", err.Error())
+		http.Error(w, "Invalid HTTP Body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	tmpl.Name = name
+	err = tmpl.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.saveTemplate(tmpl)
+	if err != nil {
+		log.Printf("PutTemplate: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	err = tr.SendJson(tmpl, w)
+	if err != nil {
+		log.Printf("PutTemplate: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// DeleteTemplate removes a stored template.
+func (s *Server) DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	name := mux.Vars(r)["name"]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !templateName.MatchString(name) {
+		http.Error(w, "No template has that name.", http.StatusNotFound)
+		return
+	}
+	err := os.Remove(s.templateFile(name))
+	if errors.Is(err, os.ErrNotExist) {
+		http.Error(w, "No template has that name.", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("DeleteTemplate: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApplyTemplate builds a schedule from a template for the day
+// given by the query date, or today if there is none. Today's
+// schedule is replaced, as with ReplaceSchedule, and may be tried
+// out with dry_run=true. For a later day, the schedule is staged
+// as that day's plan, replacing any plan already made for it.
+func (s *Server) ApplyTemplate(w http.ResponseWriter, r *http.Request) {
+	// TODO authenticate
+	dryRun, err := isDryRun(r)
+	if err != nil {
+		http.Error(w, "Invalid value for dry_run.", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	day := s.today()
+	if q := r.URL.Query().Get("date"); q != "" {
+		day, err = time.ParseInLocation(time.DateOnly, q, s.now().Location())
+		if err != nil || day.Before(s.today()) {
+			http.Error(w, "Please give a date from today on, formatted as "+time.DateOnly+".", http.StatusBadRequest)
+			return
+		}
+	}
+	tmpl, err := s.loadTemplate(mux.Vars(r)["name"])
+	if err != nil {
+		log.Printf("ApplyTemplate: %s", err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if tmpl == nil {
+		http.Error(w, "No template has that name.", http.StatusNotFound)
+		return
+	}
+	tasks, err := tmpl.TasksOn(tr.StartOfDay(day, s.DayStart))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if day.Equal(s.today()) {
+		s.applyTasks(w, tasks, buildReplace, dryRun)
+		return
+	}
+	plan := s.newSchedule(tr.TaskList{}, day)
+	err = plan.Replace(tasks...)
+	if err != nil {
+		writeBuildError(w, err)
+		return
+	}
+	if dryRun {
+		err = tr.SendJson(NewScheduleModel(plan), w)
+		if err != nil {
+			log.Printf("ApplyTemplate: %s", err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	s.respondWithPlan(w, plan)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tr "github.com/dethancosta/timeruler/internal"
+)
+
+func TestTemplates(t *testing.T) {
+	s, c := newTestServer(t)
+	router := s.Routes()
+
+	routine := `{"Tasks": [
+		{"Description": "Standup", "Start": "09:00", "End": "15m", "Repeat": "weekdays"},
+		{"Description": "Review", "Start": "13:00", "End": "14:00", "Repeat": "weekends"},
+		{"Description": "Plan", "Start": "14:00", "End": "14:30"}
+	]}`
+	w := serve(t, router, "PUT", "/v1/templates/routine", "application/json", routine)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = serve(t, router, "PUT", "/v1/templates/bad", "application/json", `{"Tasks": [{"Description": "Task", "Start": "09:00", "End": "10:00", "Repeat": "someday"}]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for a bad recurrence, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = serve(t, router, "PUT", "/v1/templates/a.b", "application/json", routine)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for a bad name, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = serve(t, router, "GET", "/v1/templates", "application/json", "")
+	if !strings.Contains(w.Body.String(), `"templates":["routine"]`) {
+		t.Fatalf("Expected the routine template to be listed, got %s", w.Body.String())
+	}
+	w = serve(t, router, "GET", "/v1/templates/routine", "application/json", "")
+	if !strings.Contains(w.Body.String(), `"Repeat":"weekends"`) {
+		t.Fatalf("Expected the template's recurrence to be kept, got %s", w.Body.String())
+	}
+
+	// Applying to today replaces the live schedule, a Sunday
+	w = serve(t, router, "POST", "/v1/templates/routine/apply?dry_run=true", "application/json", "")
+	if got := descriptions(t, w); got != "[Review Plan]" {
+		t.Fatalf("Expected [Review Plan], got %s", got)
+	}
+	if s.Schedule.Tasks[0].Description != "Task 1" {
+		t.Fatalf("Expected a dry run to leave the schedule unchanged")
+	}
+	w = serve(t, router, "POST", "/v1/templates/routine/apply", "application/json", "")
+	if got := descriptions(t, w); got != "[Review Plan]" {
+		t.Fatalf("Expected [Review Plan], got %s", got)
+	}
+
+	// The applied schedule can be edited without changing the template
+	day := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.Local)
+	body, _ := json.Marshal([]tr.Task{tr.NewTask("Call", day.Add(13*time.Hour+30*time.Minute), day.Add(14*time.Hour+15*time.Minute))})
+	w = serve(t, router, "PUT", "/v1/schedule", "application/json", string(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	w = serve(t, router, "GET", "/v1/schedule", "application/json", "")
+	if got := descriptions(t, w); got != "[Review Call Review Plan]" {
+		t.Fatalf("Expected [Review Call Review Plan], got %s", got)
+	}
+	w = serve(t, router, "GET", "/v1/templates/routine", "application/json", "")
+	if strings.Contains(w.Body.String(), "Call") {
+		t.Fatalf("Expected the template to be unchanged, got %s", w.Body.String())
+	}
+
+	// Applying to a later day stages a plan
+	w = serve(t, router, "POST", "/v1/templates/routine/apply?date=2024-03-11", "application/json", "")
+	if got := descriptions(t, w); got != "[Standup Break Plan]" {
+		t.Fatalf("Expected [Standup Break Plan], got %s", got)
+	}
+	w = serve(t, router, "GET", "/v1/plans/2024-03-11", "application/json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the plan to be staged, got status %d", w.Code)
+	}
+	w = serve(t, router, "POST", "/v1/templates/routine/apply?date=2024-03-09", "application/json", "")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d for a past date, got %d", http.StatusBadRequest, w.Code)
+	}
+	w = serve(t, router, "POST", "/v1/templates/missing/apply", "application/json", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+
+	// Days without a plan are built from the server's template
+	s.Template = "routine"
+	c.Set(time.Date(2024, time.March, 12, 8, 0, 0, 0, time.Local))
+	s.RollOver()
+	if s.Schedule == nil || s.Schedule.Tasks[0].Description != "Standup" {
+		t.Fatalf("Expected the schedule to be built from the template, got: %v", s.Schedule)
+	}
+
+	w = serve(t, router, "DELETE", "/v1/templates/routine", "application/json", "")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	w = serve(t, router, "GET", "/v1/templates/routine", "application/json", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
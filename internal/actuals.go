@@ -0,0 +1,146 @@
+package internal
+
+import "time"
+
+// Actual is a span of time in which a task was really worked on,
+// or a record that a task was skipped.
+type Actual struct {
+	TaskID      string    `json:"TaskID"` // The planned task's ID; empty for unplanned work
+	Description string    `json:"Description"`
+	Tag         string    `json:"Tag"`
+	Start       time.Time `json:"Start"`
+	End         time.Time `json:"End"` // Zero while the task is running
+	Skipped     bool      `json:"Skipped"`
+}
+
+// IsRunning returns true if the task is still being worked on.
+func (a Actual) IsRunning() bool {
+	return !a.Skipped && a.End.IsZero()
+}
+
+// spent returns the time spent on the task up to now.
+func (a Actual) spent(now time.Time) time.Duration {
+	end := a.End
+	if a.IsRunning() {
+		end = now
+	}
+	if a.Skipped || !end.After(a.Start) {
+		return 0
+	}
+	return end.Sub(a.Start)
+}
+
+// Actuals is the log of what was really done in a day, kept apart
+// from the schedule so that changes to the plan don't change it.
+// At most one task is running at a time, and it is the last one.
+type Actuals []Actual
+
+// Running returns the task being worked on, or nil if there is none.
+func (a Actuals) Running() *Actual {
+	if len(a) == 0 || !a[len(a)-1].IsRunning() {
+		return nil
+	}
+	return &a[len(a)-1]
+}
+
+// Start records that t was started at the given time, and that the
+// task that was running stopped then. Nothing changes if t is
+// already running.
+func (a *Actuals) Start(t Task, at time.Time) {
+	if r := a.Running(); r != nil {
+		if r.TaskID == t.ID && r.Description == t.Description {
+			return
+		}
+		r.End = at
+	}
+	*a = append(*a, Actual{
+		TaskID:      t.ID,
+		Description: t.Description,
+		Tag:         t.Tag,
+		Start:       at,
+	})
+}
+
+// Stop records that the running task stopped at the given time.
+// It returns a TaskNotFoundError if no task is running.
+func (a *Actuals) Stop(at time.Time) error {
+	r := a.Running()
+	if r == nil {
+		return TaskNotFoundError{"No task is running."}
+	}
+	r.End = at
+	return nil
+}
+
+// Skip records that t was skipped at the given time. If t is
+// running, it is stopped first; if another task is running, it
+// keeps running and stays last in the log.
+func (a *Actuals) Skip(t Task, at time.Time) {
+	skipped := Actual{
+		TaskID:      t.ID,
+		Description: t.Description,
+		Tag:         t.Tag,
+		Start:       at,
+		End:         at,
+		Skipped:     true,
+	}
+	r := a.Running()
+	if r == nil {
+		*a = append(*a, skipped)
+		return
+	}
+	if r.TaskID == t.ID && r.Description == t.Description {
+		r.End = at
+		*a = append(*a, skipped)
+		return
+	}
+	running := *r
+	(*a)[len(*a)-1] = skipped
+	*a = append(*a, running)
+}
+
+// TaskComparison compares a planned task with what was really done.
+type TaskComparison struct {
+	Task    Task       `json:"Task"`
+	Started *time.Time `json:"Started"` // When work on the task first started; nil if it never did
+	Spent   Duration   `json:"Spent"`   // The time really spent on the task
+	Skipped bool       `json:"Skipped"`
+}
+
+// Comparison compares a day's plan with what was really done.
+type Comparison struct {
+	Tasks     []TaskComparison `json:"Tasks"`
+	Unplanned []Actual         `json:"Unplanned"` // Work on tasks that aren't in the plan
+}
+
+// Compare compares the tasks planned in tl, other than breaks,
+// with the log. Time spent on a running task is counted up to now.
+func (a Actuals) Compare(tl TaskList, now time.Time) Comparison {
+	c := Comparison{Tasks: []TaskComparison{}, Unplanned: []Actual{}}
+	planned := make(map[string]int)
+	for _, t := range tl {
+		if t.IsBreak() {
+			continue
+		}
+		planned[t.ID] = len(c.Tasks)
+		c.Tasks = append(c.Tasks, TaskComparison{Task: *t})
+	}
+	for _, actual := range a {
+		i, ok := planned[actual.TaskID]
+		if !ok || actual.TaskID == "" {
+			c.Unplanned = append(c.Unplanned, actual)
+			continue
+		}
+		tc := &c.Tasks[i]
+		if actual.Skipped {
+			tc.Skipped = true
+			continue
+		}
+		if tc.Started == nil {
+			started := actual.Start
+			tc.Started = &started
+		}
+		tc.Spent += Duration(actual.spent(now))
+	}
+	return c
+}
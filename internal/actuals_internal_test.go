@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestActuals(t *testing.T) {
+	tl := strategyTasks(t)
+	a, b, c := *tl[0], *tl[1], *tl[4]
+
+	var log Actuals
+	if err := log.Stop(at(9, 0)); !errors.As(err, &TaskNotFoundError{}) {
+		t.Fatalf("Expected TaskNotFoundError with nothing running, got: %v", err)
+	}
+	log.Start(a, at(9, 10))
+	log.Start(a, at(9, 20))
+	if len(log) != 1 || !log.Running().Start.Equal(at(9, 10)) {
+		t.Fatalf("Expected starting a running task to change nothing, got %+v", log)
+	}
+	log.Start(NewTask("Email", at(9, 30), at(9, 45)), at(9, 30))
+	log.Start(a, at(9, 45))
+	log.Start(b, at(10, 15))
+	err := log.Stop(at(10, 40))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if log.Running() != nil {
+		t.Fatalf("Expected nothing to be running, got %+v", log.Running())
+	}
+	log.Start(c, at(12, 0))
+	log.Skip(c, at(12, 5))
+	log.Start(a, at(12, 30))
+
+	comparison := log.Compare(tl, at(12, 40))
+	if len(comparison.Tasks) != 5 {
+		t.Fatalf("Expected a comparison for each planned task, got %+v", comparison.Tasks)
+	}
+	ca, cb, cc := comparison.Tasks[0], comparison.Tasks[1], comparison.Tasks[3]
+	if ca.Started == nil || !ca.Started.Equal(at(9, 10)) || time.Duration(ca.Spent) != 60*time.Minute {
+		t.Fatalf("Expected A to be started at 09:10 and take an hour, got %+v", ca)
+	}
+	if time.Duration(cb.Spent) != 25*time.Minute || cb.Skipped {
+		t.Fatalf("Expected B to take 25 minutes, got %+v", cb)
+	}
+	if !cc.Skipped || time.Duration(cc.Spent) != 5*time.Minute {
+		t.Fatalf("Expected C to be skipped after 5 minutes, got %+v", cc)
+	}
+	if comparison.Tasks[2].Started != nil {
+		t.Fatalf("Expected the meeting never to be started, got %+v", comparison.Tasks[2])
+	}
+	if len(comparison.Unplanned) != 1 || comparison.Unplanned[0].Description != "Email" {
+		t.Fatalf("Expected Email to be unplanned, got %+v", comparison.Unplanned)
+	}
+
+	// Tasks that aren't in the schedule have no ID, so they are told apart by description
+	var adHoc Actuals
+	adHoc.Start(Task{Description: "Email"}, at(9, 0))
+	adHoc.Skip(Task{Description: "Call"}, at(9, 10))
+	if r := adHoc.Running(); r == nil || r.Description != "Email" || !adHoc[0].Skipped {
+		t.Fatalf("Expected skipping Call not to stop Email, got %+v", adHoc)
+	}
+}
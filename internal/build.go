@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Format is a file format that a schedule can be built from.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatICS  Format = "ics"
+	FormatJSON Format = "json"
+)
+
+// FormatFromFileName returns the format of the file with the
+// given name, going by its extension. Files with an unknown
+// extension are assumed to be csv.
+func FormatFromFileName(name string) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".ics", ".ical", ".ifb":
+		return FormatICS
+	case ".json":
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// FormatFromMediaType returns the format for the given media
+// type, such as text/csv. It returns false if the media type
+// isn't one that a schedule can be built from.
+func FormatFromMediaType(mediaType string) (Format, bool) {
+	switch strings.ToLower(mediaType) {
+	case "text/csv", "application/csv":
+		return FormatCSV, true
+	case "text/calendar":
+		return FormatICS, true
+	case "application/json":
+		return FormatJSON, true
+	}
+	return "", false
+}
+
+// BuildFromReader creates a schedule from a file in the given
+// format read from r, for the day the options give. Times before
+// the options' DayStart are on the next date.
+func BuildFromReader(r io.Reader, format Format, opts ScheduleOptions) (*Schedule, error) {
+	opts.Day = opts.day()
+	tasks, err := ParseTasks(r, format, StartOfDay(opts.Day, opts.DayStart))
+	if err != nil {
+		return nil, fmt.Errorf("BuildFromReader: %w", err)
+	}
+	return scheduleFromTasks(tasks, opts)
+}
+
+// ParseTasks reads the tasks in a file of the given format.
+// Tasks in csv and iCalendar files are placed on the day that
+// begins at the given time; see ParseCSV and ParseICS.
+func ParseTasks(r io.Reader, format Format, day time.Time) ([]Task, error) {
+	switch format {
+	case FormatCSV:
+		return ParseCSV(r, day)
+	case FormatICS:
+		return ParseICS(r, day)
+	case FormatJSON:
+		return ParseJSON(r)
+	}
+	return nil, InvalidScheduleError{fmt.Sprintf("Unknown schedule format: %q", format)}
+}
+
+// ParseJSON reads a JSON array of tasks, in the same
+// form that tasks are sent to and from the server.
+func ParseJSON(r io.Reader) ([]Task, error) {
+	var tasks []Task
+	err := json.NewDecoder(r).Decode(&tasks)
+	if err != nil {
+		return nil, fmt.Errorf("ParseJSON: %w", err)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, time.March, 10, 9, 0, 0, 0, time.Local)
+	c := NewFakeClock(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("Expected: %v, Got: %v", start, c.Now())
+	}
+	c.Advance(90 * time.Minute)
+	if !c.Now().Equal(start.Add(90 * time.Minute)) {
+		t.Fatalf("Expected: %v, Got: %v", start.Add(90*time.Minute), c.Now())
+	}
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Fatalf("Expected: %v, Got: %v", start, c.Now())
+	}
+}
+
+func TestFakeClockTimers(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 9, 0, 0, 0, time.Local))
+	early := c.NewTimer(5 * time.Minute)
+	late := c.NewTimer(10 * time.Minute)
+	stopped := c.NewTimer(5 * time.Minute)
+	if !stopped.Stop() {
+		t.Fatalf("Stopping a pending timer should return true")
+	}
+	if c.Timers() != 2 {
+		t.Fatalf("Expected 2 pending timers, got %d", c.Timers())
+	}
+
+	c.Advance(5 * time.Minute)
+	select {
+	case <-early.C():
+	default:
+		t.Fatalf("Timer should fire once its deadline is reached")
+	}
+	select {
+	case <-late.C():
+		t.Fatalf("Timer should not fire before its deadline")
+	case <-stopped.C():
+		t.Fatalf("Stopped timer should not fire")
+	default:
+	}
+
+	c.Advance(5 * time.Minute)
+	select {
+	case <-late.C():
+	default:
+		t.Fatalf("Timer should fire once its deadline is reached")
+	}
+	if c.Timers() != 0 {
+		t.Fatalf("Expected no pending timers, got %d", c.Timers())
+	}
+}
+
+func TestTransitionsWithFakeClock(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 9, 10, 0, 0, time.Local))
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if y, m, d := sched.Tasks[0].StartTime.Date(); y != 2024 || m != time.March || d != 10 {
+		t.Fatalf("Expected tasks on the clock's day, got: %s", sched.Tasks[0].String())
+	}
+	if sched.CurrentTask == nil || sched.CurrentTask.Description != "Eat Breakfast" {
+		t.Fatalf("Expected current task Eat Breakfast, got: %v", sched.CurrentTask)
+	}
+
+	steps := []struct {
+		at   time.Duration
+		desc string
+	}{
+		{10 * time.Minute, "Break"},
+		{3 * time.Hour, "Eat Lunch"},
+		{30 * time.Minute, "Break"},
+		{5 * time.Hour, "Eat Dinner"},
+	}
+	for _, step := range steps {
+		c.Advance(step.at)
+		err = sched.UpdateCurrentTask()
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		if sched.CurrentTask.Description != step.desc {
+			t.Fatalf("At %s expected %s, got %s", c.Now().Format(time.TimeOnly), step.desc, sched.CurrentTask.Description)
+		}
+	}
+
+	c.Set(time.Date(2024, time.March, 10, 23, 50, 0, 0, time.Local))
+	err = sched.UpdateCurrentTask()
+	if err == nil || sched.CurrentTask != nil {
+		t.Fatalf("Expected no current task after the schedule ends, got: %v", sched.CurrentTask)
+	}
+}
+
+func TestDayRolloverWithFakeClock(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 23, 20, 0, 0, time.Local))
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	err = sched.ChangeCurrentTaskUntil("Read", "", time.Date(2024, time.March, 11, 0, 15, 0, 0, time.Local))
+	if err == nil {
+		t.Fatalf("Expected error when the task ends after midnight")
+	}
+	err = sched.ChangeCurrentTaskUntil("Read", "", time.Date(2024, time.March, 10, 23, 40, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	read, _ := sched.Tasks.GetTaskAtTime(c.Now().Add(time.Minute))
+	if read.Description != "Read" ||
+		read.StartTime.Format(time.TimeOnly) != "23:20:00" ||
+		read.EndTime.Format(time.TimeOnly) != "23:40:00" {
+		t.Fatalf("Expected Read from 23:20 to 23:40, got: %s", read.String())
+	}
+
+	// Once the day is over, yesterday's tasks can no longer be updated
+	c.Set(time.Date(2024, time.March, 11, 0, 5, 0, 0, time.Local))
+	late := NewTask("Late", time.Date(2024, time.March, 10, 23, 45, 0, 0, time.Local),
+		time.Date(2024, time.March, 10, 23, 55, 0, 0, time.Local))
+	err = sched.UpdateTimeBlock(late)
+	if err == nil {
+		t.Fatalf("Expected error when updating a task from the previous day")
+	}
+}
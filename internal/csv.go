@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSV columns that a schedule file can have.
+const (
+	csvDescription = "description"
+	csvStart       = "start"
+	csvEnd         = "end"
+	csvDuration    = "duration"
+	csvTag         = "tag"
+)
+
+// csvColumnNames maps the names accepted in a header
+// row to the column they refer to.
+var csvColumnNames = map[string]string{
+	"description": csvDescription,
+	"desc":        csvDescription,
+	"task":        csvDescription,
+	"name":        csvDescription,
+	"start":       csvStart,
+	"start time":  csvStart,
+	"from":        csvStart,
+	"end":         csvEnd,
+	"end time":    csvEnd,
+	"until":       csvEnd,
+	"to":          csvEnd,
+	"duration":    csvDuration,
+	"length":      csvDuration,
+	"tag":         csvTag,
+	"category":    csvTag,
+}
+
+// csvDefaultColumns is the column order of a file without a header.
+var csvDefaultColumns = []string{csvDescription, csvStart, csvEnd, csvTag}
+
+// ParseCSV reads a schedule from a CSV file and returns its tasks
+// on the day beginning at the given time, in the day's time zone.
+// The day usually begins at midnight, but if it begins later (see
+// StartOfDay) then times before its start are on the next date.
+// An end time earlier than the task's start time is on the next
+// date too, so tasks can run past midnight.
+//
+// The first row may be a header naming the columns, in any order:
+// description, start, end, duration and tag. Without a header the
+// columns are description, start, end and an optional tag. Times
+// may be given as 15:04:05, 15:04, 3pm or 3:30pm, and a duration
+// such as 45m or 1h30m may be given instead of an end time.
+//
+// Every line is checked, and a CSVError listing each bad line is
+// returned if any of them can't be read.
+func ParseCSV(r io.Reader, day time.Time) ([]Task, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var columns []string
+	header := true // Whether the next record might be a header
+	tasks := []Task{}
+	var lineErrs []CSVLineError
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			lineErrs = append(lineErrs, CSVLineError{parseErr.StartLine, parseErr.Err})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("ParseCSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		if header {
+			header = false
+			var isHeader bool
+			columns, isHeader, err = csvColumns(record)
+			if err != nil {
+				return nil, fmt.Errorf("ParseCSV: %w", CSVError{[]CSVLineError{{line, err}}})
+			}
+			if isHeader {
+				continue
+			}
+		}
+
+		task, err := csvTask(record, columns, day)
+		if err != nil {
+			lineErrs = append(lineErrs, CSVLineError{line, err})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	if len(lineErrs) > 0 {
+		return nil, fmt.Errorf("ParseCSV: %w", CSVError{lineErrs})
+	}
+
+	return tasks, nil
+}
+
+// csvColumns returns the column of each field in a row, and whether
+// the row is a header. A row is a header if every one of its fields
+// names a column; otherwise the default columns are used.
+func csvColumns(record []string) ([]string, bool, error) {
+	columns := make([]string, len(record))
+	for i, field := range record {
+		column, ok := csvColumnNames[strings.ToLower(strings.TrimSpace(field))]
+		if !ok {
+			return csvDefaultColumns, false, nil
+		}
+		columns[i] = column
+	}
+
+	seen := make(map[string]bool)
+	for i, column := range columns {
+		if seen[column] {
+			return nil, false, fmt.Errorf("column %q is given more than once", strings.TrimSpace(record[i]))
+		}
+		seen[column] = true
+	}
+	if !seen[csvDescription] || !seen[csvStart] {
+		return nil, false, errors.New("header must have description and start columns")
+	}
+	if seen[csvEnd] == seen[csvDuration] {
+		return nil, false, errors.New("header must have either an end or a duration column")
+	}
+	return columns, true, nil
+}
+
+// csvTask returns the task described by a row of a CSV file.
+func csvTask(record []string, columns []string, day time.Time) (Task, error) {
+	fields := make(map[string]string)
+	for i, field := range record {
+		if i >= len(columns) {
+			if strings.TrimSpace(field) != "" {
+				return Task{}, fmt.Errorf("too many fields: expected at most %d", len(columns))
+			}
+			continue
+		}
+		fields[columns[i]] = strings.TrimSpace(field)
+	}
+
+	desc := fields[csvDescription]
+	if desc == "" {
+		return Task{}, errors.New("missing description")
+	}
+	if fields[csvStart] == "" {
+		return Task{}, errors.New("missing start time")
+	}
+	start, err := parseTimeOfDay(fields[csvStart], day)
+	if err != nil {
+		return Task{}, fmt.Errorf("start time: %w", err)
+	}
+	if start.Before(day) {
+		start = start.AddDate(0, 0, 1)
+	}
+
+	var end time.Time
+	switch {
+	case fields[csvDuration] != "":
+		d, err := time.ParseDuration(fields[csvDuration])
+		if err != nil || d <= 0 {
+			return Task{}, fmt.Errorf("duration %q should be positive, such as 45m or 1h30m", fields[csvDuration])
+		}
+		end = start.Add(d)
+	case fields[csvEnd] != "":
+		// Files without a header may give a duration in the end column
+		end, err = parseEndTime(fields[csvEnd], start)
+		if err != nil {
+			return Task{}, fmt.Errorf("end time: %w", err)
+		}
+	default:
+		return Task{}, errors.New("missing end time or duration")
+	}
+
+	task := NewTask(desc, start, end).WithTag(fields[csvTag])
+	if task.IsEmpty() {
+		return Task{}, fmt.Errorf("task must end at least 5 minutes after it starts (%s to %s)",
+			start.Format(time.TimeOnly), end.Format(time.TimeOnly))
+	}
+	return task, nil
+}
+
+// parseEndTime parses the end of a task that begins at start,
+// given either as a time of day or as a duration such as 45m.
+// A time of day earlier than start is on the next date.
+func parseEndTime(value string, start time.Time) (time.Time, error) {
+	end, err := parseTimeOfDay(value, start)
+	if err != nil {
+		d, durErr := time.ParseDuration(value)
+		if durErr != nil || d <= 0 {
+			return time.Time{}, err
+		}
+		return start.Add(d), nil
+	}
+	if end.Before(start) {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end, nil
+}
+
+// parseTimeOfDay parses a time of day such as 15:04:05, 15:04,
+// 3pm or 3:30 PM, and returns that time on the given day.
+func parseTimeOfDay(value string, day time.Time) (time.Time, error) {
+	v := strings.ToLower(strings.ReplaceAll(value, " ", ""))
+
+	var hour, min, sec int
+	meridiem := ""
+	if strings.HasSuffix(v, "am") || strings.HasSuffix(v, "pm") {
+		meridiem = v[len(v)-2:]
+		v = v[:len(v)-2]
+	}
+	parts := strings.Split(v, ":")
+	if len(parts) > 3 || (meridiem == "" && len(parts) < 2) {
+		return time.Time{}, InvalidTimeError{fmt.Sprintf("%q is not a time such as 15:04, 15:04:05 or 3pm", value)}
+	}
+	fields := []*int{&hour, &min, &sec}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (i == 0 && len(p) > 2) || (i > 0 && len(p) != 2) {
+			return time.Time{}, InvalidTimeError{fmt.Sprintf("%q is not a time such as 15:04, 15:04:05 or 3pm", value)}
+		}
+		*fields[i] = n
+	}
+
+	if meridiem != "" {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, InvalidTimeError{fmt.Sprintf("%q has an hour outside 1 to 12", value)}
+		}
+		hour %= 12
+		if meridiem == "pm" {
+			hour += 12
+		}
+	}
+	if hour > 23 || min > 59 || sec > 59 {
+		return time.Time{}, InvalidTimeError{fmt.Sprintf("%q is not a valid time of day", value)}
+	}
+
+	// Seconds are dropped, as tasks are quantized to minutes
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, day.Location()), nil
+}
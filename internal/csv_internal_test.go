@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCSVHeader(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 8, 0, 0, 0, time.Local))
+	sched, err := BuildFromFile("./test_data/named_columns.csv", ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sched.CurrentID = -1
+
+	expected := `
+	[09:00:00-09:15:00] Eat Breakfast (food)
+	[09:15:00-12:15:00] Break (break)
+	[12:15:00-12:45:00] Eat Lunch, outside (food)
+	[12:45:00-17:00:00] Break (break)
+	[17:00:00-18:00:00] Eat Dinner (food)
+	[18:00:00-23:30:00] Break (break)
+	[23:30:00-23:45:00] Go To Sleep ()`
+	got := strings.Join(strings.Fields(sched.String()), "")
+	if strings.Join(strings.Fields(expected), "") != got {
+		t.Fatalf("Expected: %s\n Got: %s", expected, sched.String())
+	}
+}
+
+func TestParseCSVFormats(t *testing.T) {
+	day := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+	input := `Read,7:30am,08:15
+Write,8:15,45m,work
+Walk,12pm,12:30:00,
+`
+	tasks, err := ParseCSV(strings.NewReader(input), day)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(tasks) != 3 {
+		t.Fatalf("Expected 3 tasks, got %d", len(tasks))
+	}
+	expected := []struct {
+		start, end string
+		tag        string
+	}{
+		{"07:30:00", "08:15:00", ""},
+		{"08:15:00", "09:00:00", "work"},
+		{"12:00:00", "12:30:00", ""},
+	}
+	for i, e := range expected {
+		if tasks[i].StartTime.Format(time.TimeOnly) != e.start ||
+			tasks[i].EndTime.Format(time.TimeOnly) != e.end || tasks[i].Tag != e.tag {
+			t.Fatalf("Expected [%s-%s] (%s), got: %s", e.start, e.end, e.tag, tasks[i].String())
+		}
+	}
+}
+
+func TestParseCSVErrors(t *testing.T) {
+	day := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+	input := `Read,7:30am,08:15
+Write,8:15
+Walk,13pm,14:00
+
+,9:00,10:00
+Nap,15:00,15:02
+Run,16:00,17:00,exercise,extra
+`
+	_, err := ParseCSV(strings.NewReader(input), day)
+	var csvErr CSVError
+	if !errors.As(err, &csvErr) {
+		t.Fatalf("Expected CSVError, got: %v", err)
+	}
+	lines := []int{2, 3, 5, 6, 7}
+	if len(csvErr.Lines) != len(lines) {
+		t.Fatalf("Expected errors on lines %v, got:\n%s", lines, csvErr.Error())
+	}
+	for i, l := range lines {
+		if csvErr.Lines[i].Line != l {
+			t.Fatalf("Expected errors on lines %v, got:\n%s", lines, csvErr.Error())
+		}
+	}
+	if !errors.Is(err, InvalidTimeError{`"13pm" has an hour outside 1 to 12`}) {
+		t.Fatalf("Expected InvalidTimeError for line 3, got:\n%s", csvErr.Error())
+	}
+
+	_, err = ParseCSV(strings.NewReader("Description,Start,End,Duration\n"), day)
+	if !errors.As(err, &csvErr) || csvErr.Lines[0].Line != 1 {
+		t.Fatalf("Expected error on line 1 for header with both end and duration, got: %v", err)
+	}
+}
+
+func TestParseCSVPastMidnight(t *testing.T) {
+	// The day begins at 04:00, so 01:00 is early on the next date
+	day := time.Date(2024, time.March, 10, 4, 0, 0, 0, time.UTC)
+	input := `Work,22:00,23:30
+Late work,23:30,01:00
+Sleep,01:00,03:45
+`
+	tasks, err := ParseCSV(strings.NewReader(input), day)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := []struct{ start, end string }{
+		{"2024-03-10 22:00:00", "2024-03-10 23:30:00"},
+		{"2024-03-10 23:30:00", "2024-03-11 01:00:00"},
+		{"2024-03-11 01:00:00", "2024-03-11 03:45:00"},
+	}
+	for i, e := range expected {
+		if tasks[i].StartTime.Format(time.DateTime) != e.start || tasks[i].EndTime.Format(time.DateTime) != e.end {
+			t.Fatalf("Expected %s to %s, got: %s to %s", e.start, e.end,
+				tasks[i].StartTime.Format(time.DateTime), tasks[i].EndTime.Format(time.DateTime))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+)
+
+// A schedule covers one day, but the day doesn't have to begin
+// at midnight. With a day start of 04:00, for example, 02:00 on
+// the 11th still belongs to the 10th. Day starts are given as the
+// time after midnight, and are applied to the wall clock so that
+// they are unaffected by daylight saving time changes.
+
+// DayOf returns midnight at the start of the date that t
+// belongs to, when days begin at dayStart after midnight.
+func DayOf(t time.Time, dayStart time.Duration) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if t.Before(StartOfDay(day, dayStart)) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// StartOfDay returns the time at which the given day
+// begins, when days begin at dayStart after midnight.
+func StartOfDay(day time.Time, dayStart time.Duration) time.Time {
+	h := int(dayStart / time.Hour)
+	m := int(dayStart % time.Hour / time.Minute)
+	return time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, day.Location())
+}
+
+// NextDayStart returns the time at which the day after
+// the one t belongs to begins.
+func NextDayStart(t time.Time, dayStart time.Duration) time.Time {
+	return StartOfDay(DayOf(t, dayStart).AddDate(0, 0, 1), dayStart)
+}
+
+// ParseDayStart parses the time of day at which days begin,
+// such as 04:00 or 4am, as the time after midnight.
+func ParseDayStart(value string) (time.Duration, error) {
+	t, err := parseTimeOfDay(value, time.Time{})
+	if err != nil {
+		return 0, fmt.Errorf("ParseDayStart: %w", err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
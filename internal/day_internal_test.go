@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDayOf(t *testing.T) {
+	dayStart := 4 * time.Hour
+	tests := []struct {
+		t        time.Time
+		day      string
+		nextDay  string
+		dayStart time.Duration
+	}{
+		{time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC), "2024-03-10", "2024-03-11T00:00:00Z", 0},
+		{time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC), "2024-03-10", "2024-03-11T00:00:00Z", 0},
+		{time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC), "2024-03-10", "2024-03-11T04:00:00Z", dayStart},
+		{time.Date(2024, time.March, 11, 2, 0, 0, 0, time.UTC), "2024-03-10", "2024-03-11T04:00:00Z", dayStart},
+		{time.Date(2024, time.March, 11, 4, 0, 0, 0, time.UTC), "2024-03-11", "2024-03-12T04:00:00Z", dayStart},
+	}
+	for _, test := range tests {
+		day := DayOf(test.t, test.dayStart)
+		if day.Format(time.DateOnly) != test.day || day.Hour() != 0 {
+			t.Fatalf("%v: expected day %s, got %v", test.t, test.day, day)
+		}
+		next := NextDayStart(test.t, test.dayStart)
+		if next.Format(time.RFC3339) != test.nextDay {
+			t.Fatalf("%v: expected next day to start at %s, got %s", test.t, test.nextDay, next.Format(time.RFC3339))
+		}
+	}
+
+	// The day starts by the wall clock, even when the clocks change
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	next := NextDayStart(time.Date(2024, time.March, 9, 12, 0, 0, 0, ny), dayStart)
+	if next.Hour() != 4 || next.Day() != 10 {
+		t.Fatalf("Expected next day to start at 04:00 on the 10th, got %v", next)
+	}
+}
+
+func TestParseDayStart(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"00:00": 0,
+		"04:00": 4 * time.Hour,
+		"4:30":  4*time.Hour + 30*time.Minute,
+		"5am":   5 * time.Hour,
+	} {
+		d, err := ParseDayStart(value)
+		if err != nil {
+			t.Fatalf("%s: %s", value, err.Error())
+		}
+		if d != expected {
+			t.Fatalf("%s: expected %v, got %v", value, expected, d)
+		}
+	}
+	_, err := ParseDayStart("25:00")
+	if err == nil {
+		t.Fatalf("Expected error for an invalid day start")
+	}
+}
+
+func TestScheduleAcrossMidnight(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 23, 0, 0, 0, time.UTC))
+	tl, err := NewTaskList(NewTask("Work", c.Now().Add(-time.Hour), c.Now()))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sched := NewSchedule(tl, ScheduleOptions{Clock: c, DayStart: 4 * time.Hour})
+
+	start, end := sched.Bounds()
+	if start.Format(time.DateTime) != "2024-03-10 04:00:00" || end.Format(time.DateTime) != "2024-03-11 04:00:00" {
+		t.Fatalf("Expected the day to run from 04:00 to 04:00, got %v to %v", start, end)
+	}
+
+	late := NewTask("Late work", c.Now().Add(30*time.Minute), c.Now().Add(2*time.Hour))
+	err = sched.UpdateTimeBlock(late)
+	if err != nil {
+		t.Fatalf("Expected a task past midnight to be accepted: %s", err.Error())
+	}
+	tooLate := NewTask("Too late", end.Add(-30*time.Minute), end.Add(30*time.Minute))
+	err = sched.UpdateTimeBlock(tooLate)
+	if !errors.As(err, &InvalidTimeError{}) {
+		t.Fatalf("Expected InvalidTimeError for a task past the end of the day, got: %v", err)
+	}
+
+	c.Set(time.Date(2024, time.March, 10, 23, 15, 0, 0, time.UTC))
+	err = sched.ChangeCurrentTaskUntil("Read", "", c.Now().Add(15*time.Minute))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// Past midnight the schedule's day is still going
+	c.Set(time.Date(2024, time.March, 11, 1, 30, 0, 0, time.UTC))
+	err = sched.ChangeCurrentTaskUntil("Sleep", "", time.Date(2024, time.March, 11, 3, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected the current task to be changed after midnight: %s", err.Error())
+	}
+	err = sched.ChangeCurrentTaskUntil("Sleep", "", time.Date(2024, time.March, 11, 5, 0, 0, 0, time.UTC))
+	if !errors.As(err, &InvalidTimeError{}) {
+		t.Fatalf("Expected InvalidTimeError for a task ending after the day, got: %v", err)
+	}
+
+	c.Set(time.Date(2024, time.March, 11, 4, 30, 0, 0, time.UTC))
+	err = sched.UpdateTimeBlock(NewTask("Breakfast", c.Now().Add(time.Hour), c.Now().Add(2*time.Hour)))
+	if !errors.As(err, &InvalidTimeError{}) {
+		t.Fatalf("Expected InvalidTimeError once the day is over, got: %v", err)
+	}
+}
+
+func TestBuildWithDayStart(t *testing.T) {
+	// At 02:00 on the 11th, it's still the 10th when days begin at 04:00
+	c := NewFakeClock(time.Date(2024, time.March, 11, 2, 0, 0, 0, time.UTC))
+	input := `Work,22:00,23:30
+Late work,23:30,01:00
+Sleep,01:00,03:45
+`
+	sched, err := BuildFromReader(strings.NewReader(input), FormatCSV, ScheduleOptions{Clock: c, DayStart: 4 * time.Hour})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.Day.Format(time.DateOnly) != "2024-03-10" || sched.DayStart != 4*time.Hour {
+		t.Fatalf("Expected the schedule to be for the 10th from 04:00, got %v from %v", sched.Day, sched.DayStart)
+	}
+	if sched.CurrentTask == nil || sched.CurrentTask.Description != "Sleep" {
+		t.Fatalf("Expected Sleep to be the current task, got %v", sched.CurrentTask)
+	}
+
+	name := filepath.Join(t.TempDir(), "schedule.json")
+	err = sched.SaveToFile(name, sched.Day)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	loaded, err := ReadFromFile(name, time.UTC, 4*time.Hour)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !loaded.Day.Equal(DayOf(c.Now(), 4*time.Hour)) {
+		t.Fatalf("Expected the schedule to be for the current day, got %v", loaded.Day)
+	}
+	if loaded.DayStart != 4*time.Hour {
+		t.Fatalf("Expected the loaded schedule's day to begin at 04:00, got %v", loaded.DayStart)
+	}
+	if loaded.Day.Equal(DayOf(c.Now(), 0)) {
+		t.Fatalf("Expected the schedule not to be for the current day when days begin at midnight")
+	}
+}
@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"sort"
+	"time"
+)
+
+// ChangeKind is the kind of change made to a task by an update.
+type ChangeKind string
+
+const (
+	// TaskAdded is a task that wasn't in the schedule before.
+	TaskAdded ChangeKind = "added"
+	// TaskRemoved is a task that is no longer in the schedule.
+	TaskRemoved ChangeKind = "removed"
+	// TaskTruncated is a task cut short to a part of its old time.
+	TaskTruncated ChangeKind = "truncated"
+	// TaskMoved is a task with the same length at a different time.
+	TaskMoved ChangeKind = "moved"
+	// TaskResized is a task whose length and start time have changed.
+	TaskResized ChangeKind = "resized"
+	// TaskSplit is a task that is now done in more than one part.
+	TaskSplit ChangeKind = "split"
+	// TaskEdited is a task whose time is the same, but whose
+	// description, tag or fixedness has changed.
+	TaskEdited ChangeKind = "edited"
+	// BreaksMerged is a break that replaces two or more breaks.
+	BreaksMerged ChangeKind = "breaks_merged"
+)
+
+// TaskChange is a single change between two task lists. Before
+// holds the tasks as they were, and After the tasks they became;
+// Before is empty for an added task and After for a removed one.
+type TaskChange struct {
+	Kind   ChangeKind
+	Before []Task
+	After  []Task
+}
+
+// ScheduleDiff is the difference between two task lists,
+// with its changes in the order of the time they happen.
+type ScheduleDiff struct {
+	Changes []TaskChange
+}
+
+// IsEmpty returns true if the diff has no changes.
+func (d ScheduleDiff) IsEmpty() bool {
+	return len(d.Changes) == 0
+}
+
+// DiffTaskLists returns the changes made to the tasks in before
+// to get the tasks in after. Tasks are matched by ID, and a new
+// task with the same description and tag as a task that is still
+// in after is taken to be the rest of that task if it was split.
+// The tasks with the given IDs were added by the update itself, so
+// they are always reported as added. Breaks are only reported
+// where two or more were merged.
+func DiffTaskLists(before, after TaskList, added ...string) ScheduleDiff {
+	d := ScheduleDiff{Changes: []TaskChange{}}
+	old := make(map[string]Task)
+	for _, t := range before {
+		if !t.IsBreak() {
+			old[t.ID] = *t
+		}
+	}
+	current := make(map[string]Task)
+	for _, t := range after {
+		if !t.IsBreak() {
+			current[t.ID] = *t
+		}
+	}
+
+	explicit := make(map[string]bool)
+	for _, id := range added {
+		explicit[id] = true
+	}
+
+	// The new parts of tasks that were split, by the ID of the task
+	parts := make(map[string][]Task)
+	var newTasks []Task
+	for _, t := range after {
+		if t.IsBreak() {
+			continue
+		}
+		if _, ok := old[t.ID]; ok {
+			continue
+		}
+		if id := splitFrom(*t, before, current); id != "" && !explicit[t.ID] {
+			parts[id] = append(parts[id], *t)
+		} else {
+			newTasks = append(newTasks, *t)
+		}
+	}
+
+	for _, t := range before {
+		if t.IsBreak() {
+			continue
+		}
+		now, ok := current[t.ID]
+		switch {
+		case !ok:
+			d.Changes = append(d.Changes, TaskChange{TaskRemoved, []Task{*t}, nil})
+		case len(parts[t.ID]) > 0:
+			d.Changes = append(d.Changes, TaskChange{TaskSplit, []Task{*t}, append([]Task{now}, parts[t.ID]...)})
+		case now.sameAs(*t):
+		default:
+			d.Changes = append(d.Changes, TaskChange{changeKind(*t, now), []Task{*t}, []Task{now}})
+		}
+	}
+	for _, t := range newTasks {
+		d.Changes = append(d.Changes, TaskChange{TaskAdded, nil, []Task{t}})
+	}
+
+	for _, b := range after {
+		if !b.IsBreak() {
+			continue
+		}
+		var merged []Task
+		for _, old := range before {
+			if old.IsBreak() && !old.StartTime.Before(b.StartTime) && !old.EndTime.After(b.EndTime) {
+				merged = append(merged, *old)
+			}
+		}
+		if len(merged) > 1 {
+			d.Changes = append(d.Changes, TaskChange{BreaksMerged, merged, []Task{*b}})
+		}
+	}
+
+	sort.SliceStable(d.Changes, func(i, j int) bool {
+		return d.Changes[i].start().Before(d.Changes[j].start())
+	})
+	return d
+}
+
+// start returns the earliest time of the tasks in the change.
+func (c TaskChange) start() time.Time {
+	var start time.Time
+	for _, t := range append(append([]Task{}, c.Before...), c.After...) {
+		if start.IsZero() || t.StartTime.Before(start) {
+			start = t.StartTime
+		}
+	}
+	return start
+}
+
+// splitFrom returns the ID of the task in before that t is a part
+// of, or "" if there is none. The task must still be in current,
+// and t must start at or after the end of the part in current, and
+// be either within its old time or, if it was moved, make up the
+// rest of its old length.
+func splitFrom(t Task, before TaskList, current map[string]Task) string {
+	for _, old := range before {
+		if old.IsBreak() || old.Description != t.Description || old.Tag != t.Tag {
+			continue
+		}
+		first, ok := current[old.ID]
+		if !ok || t.StartTime.Before(first.EndTime) {
+			continue
+		}
+		within := !t.StartTime.Before(old.StartTime) && !t.EndTime.After(old.EndTime)
+		rest := first.EndTime.Sub(first.StartTime)+t.EndTime.Sub(t.StartTime) == old.EndTime.Sub(old.StartTime)
+		if within || rest {
+			return old.ID
+		}
+	}
+	return ""
+}
+
+// changeKind returns the kind of change from old to now,
+// which are the same task with different fields.
+func changeKind(old, now Task) ChangeKind {
+	oldLength := old.EndTime.Sub(old.StartTime)
+	length := now.EndTime.Sub(now.StartTime)
+	switch {
+	case now.StartTime.Equal(old.StartTime) && now.EndTime.Equal(old.EndTime):
+		return TaskEdited
+	case length == oldLength:
+		return TaskMoved
+	case length < oldLength && !now.StartTime.Before(old.StartTime) && !now.EndTime.After(old.EndTime):
+		return TaskTruncated
+	}
+	return TaskResized
+}
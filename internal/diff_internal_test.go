@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+)
+
+// changes describes each change in the diff and the tasks it's made of.
+func changes(d ScheduleDiff) []string {
+	var s []string
+	for _, c := range d.Changes {
+		s = append(s, fmt.Sprintf("%s [%s] -> [%s]", c.Kind, summary(toList(c.Before)), summary(toList(c.After))))
+	}
+	return s
+}
+
+func toList(tasks []Task) TaskList {
+	tl := TaskList{}
+	for i := range tasks {
+		tl = append(tl, &tasks[i])
+	}
+	return tl
+}
+
+func TestDiffTaskLists(t *testing.T) {
+	sched := NewSchedule(strategyTasks(t), ScheduleOptions{Clock: NewFakeClock(at(8, 0))})
+
+	split := sched.Clone()
+	err := split.UpdateTimeBlock(NewTask("Call", at(9, 30), at(9, 45)), NewTask("Lunch", at(12, 30), at(13, 30)))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := []string{
+		"split [A 09:00-10:00, ] -> [A 09:00-09:30, A 09:45-10:00, ]",
+		"added [] -> [Call 09:30-09:45, ]",
+		"truncated [C 12:00-13:00, ] -> [C 12:00-12:30, ]",
+		"added [] -> [Lunch 12:30-13:30, ]",
+	}
+	if got := changes(DiffTaskLists(sched.Tasks, split.Tasks)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v\ngot %v", expected, got)
+	}
+
+	shifted := sched.Clone()
+	_, err = shifted.UpdateTimeBlockWith(ShiftLater{}, NewTask("Call", at(10, 0), at(11, 0)))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected = []string{
+		"moved [B 10:00-10:30, ] -> [B 12:00-12:30, ]",
+		"added [] -> [Call 10:00-11:00, ]",
+		"moved [C 12:00-13:00, ] -> [C 12:30-13:30, ]",
+	}
+	if got := changes(DiffTaskLists(sched.Tasks, shifted.Tasks)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v\ngot %v", expected, got)
+	}
+
+	removed := sched.Clone()
+	err = removed.UpdateTimeBlock(NewTask("Meeting", at(10, 0), at(11, 0)).AsFixed(true))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected = []string{
+		"removed [B 10:00-10:30, ] -> []",
+		"added [] -> [Meeting 10:00-11:00, ]",
+	}
+	if got := changes(DiffTaskLists(sched.Tasks, removed.Tasks)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v\ngot %v", expected, got)
+	}
+
+	x, y, z := NewTask("X", at(9, 0), at(10, 0)), NewTask("Y", at(10, 30), at(11, 0)), NewTask("Z", at(11, 30), at(12, 0))
+	before, err := NewTaskList(x, y, z)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	after, err := NewTaskList(x, z)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected = []string{
+		"breaks_merged [Break 10:00-10:30, Break 11:00-11:30, ] -> [Break 10:00-11:30, ]",
+		"removed [Y 10:30-11:00, ] -> []",
+	}
+	if got := changes(DiffTaskLists(before, after)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v\ngot %v", expected, got)
+	}
+
+	// A task added with the same name as one it splits is still added
+	again := NewTask("A", at(9, 30), at(9, 45))
+	sameName := sched.Clone()
+	err = sameName.UpdateTimeBlock(again)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected = []string{
+		"split [A 09:00-10:00, ] -> [A 09:00-09:30, A 09:45-10:00, ]",
+		"added [] -> [A 09:30-09:45, ]",
+	}
+	if got := changes(DiffTaskLists(sched.Tasks, sameName.Tasks, again.ID)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v\ngot %v", expected, got)
+	}
+
+	// A part before the task that is still there isn't the rest of it
+	late := x
+	late.StartTime = at(9, 30)
+	before, err = NewTaskList(x)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	after, err = NewTaskList(NewTask("X", at(9, 0), at(9, 15)), late)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected = []string{
+		"truncated [X 09:00-10:00, ] -> [X 09:30-10:00, ]",
+		"added [] -> [X 09:00-09:15, ]",
+	}
+	if got := changes(DiffTaskLists(before, after)); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("Expected %v\ngot %v", expected, got)
+	}
+
+	if d := DiffTaskLists(sched.Tasks, sched.Clone().Tasks); !d.IsEmpty() {
+		t.Fatalf("Expected no changes between a schedule and its copy, got %v", changes(d))
+	}
+}
@@ -1,5 +1,10 @@
 package internal
 
+import (
+	"fmt"
+	"strings"
+)
+
 type InvalidTimeError struct {
 	msg string
 }
@@ -14,6 +19,41 @@ type IndexOutOfBoundsError struct {
 	msg string
 }
 
+type EmptyJournalError struct {
+	msg string
+}
+
+type TaskNotFoundError struct {
+	msg string
+}
+
+// TaskError is the reason a task couldn't be added to a
+// TaskList. Index is the task's position in the tasks given.
+type TaskError struct {
+	Index int
+	Task  Task
+	Err   error
+}
+
+// TaskListError lists every task that kept a
+// TaskList from being created.
+type TaskListError struct {
+	Tasks []TaskError
+}
+
+// CSVLineError is the reason a line of a CSV file
+// couldn't be read.
+type CSVLineError struct {
+	Line int
+	Err  error
+}
+
+// CSVError lists every line of a CSV file that
+// couldn't be read.
+type CSVError struct {
+	Lines []CSVLineError
+}
+
 func (e InvalidTimeError) Error() string {
 	return e.msg
 }
@@ -29,3 +69,61 @@ func (e InvalidScheduleError) Error() string {
 func (e IndexOutOfBoundsError) Error() string {
 	return "Index out of bounds"
 }
+
+func (e EmptyJournalError) Error() string {
+	return e.msg
+}
+
+func (e TaskNotFoundError) Error() string {
+	return e.msg
+}
+
+func (e TaskError) Error() string {
+	return fmt.Sprintf("task %d (%q): %s", e.Index, e.Task.Description, e.Err.Error())
+}
+
+func (e TaskError) Unwrap() error {
+	return e.Err
+}
+
+func (e TaskListError) Error() string {
+	msgs := make([]string, len(e.Tasks))
+	for i, t := range e.Tasks {
+		msgs[i] = t.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the error for each task.
+func (e TaskListError) Unwrap() []error {
+	errs := make([]error, len(e.Tasks))
+	for i, t := range e.Tasks {
+		errs[i] = t
+	}
+	return errs
+}
+
+func (e CSVLineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+func (e CSVLineError) Unwrap() error {
+	return e.Err
+}
+
+func (e CSVError) Error() string {
+	msgs := make([]string, len(e.Lines))
+	for i, l := range e.Lines {
+		msgs[i] = l.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the error for each line.
+func (e CSVError) Unwrap() []error {
+	errs := make([]error, len(e.Lines))
+	for i, l := range e.Lines {
+		errs[i] = l
+	}
+	return errs
+}
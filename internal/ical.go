@@ -0,0 +1,446 @@
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icalProperty is a single content line of an iCalendar file,
+// e.g. DTSTART;TZID=Europe/Paris:20240310T090000
+type icalProperty struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// icalEvent holds the properties of a VEVENT that are used
+// to build tasks.
+type icalEvent struct {
+	UID          string
+	Summary      string
+	Categories   string
+	Status       string
+	Start        *icalProperty
+	End          *icalProperty
+	Duration     string
+	RRule        string
+	RecurrenceID *icalProperty
+	ExDates      []*icalProperty
+}
+
+// ParseICS reads an iCalendar (RFC 5545) file and returns a Task
+// for each event that starts on the day beginning at the given
+// time, in the day's time zone. The day usually begins at midnight,
+// but may begin later (see StartOfDay). SUMMARY becomes the task's
+// description and the first of its CATEGORIES becomes its tag. The
+// tasks are fixed, since events such as meetings can't be moved by
+// one attendee. Recurring events are expanded if they have a simple
+// RRULE (daily, weekly, monthly or yearly, with INTERVAL, COUNT,
+// UNTIL, BYDAY and BYMONTHDAY). All-day events, cancelled events,
+// and events shorter than five minutes are skipped, since they
+// can't be time blocks.
+func ParseICS(r io.Reader, day time.Time) ([]Task, error) {
+	events, err := readICSEvents(r)
+	if err != nil {
+		return nil, fmt.Errorf("ParseICS: %w", err)
+	}
+
+	// Instances of recurring events that were moved or changed
+	// are given as separate events with a RECURRENCE-ID.
+	overridden := make(map[string]bool)
+	for _, e := range events {
+		if e.RecurrenceID == nil {
+			continue
+		}
+		id, err := parseICSTime(e.RecurrenceID, day.Location())
+		if err != nil {
+			return nil, fmt.Errorf("ParseICS: %w", err)
+		}
+		overridden[e.UID+"/"+id.UTC().Format(time.RFC3339)] = true
+	}
+
+	tasks := []Task{}
+	for _, e := range events {
+		if e.Start == nil || strings.EqualFold(e.Status, "CANCELLED") ||
+			e.Start.Params["VALUE"] == "DATE" {
+			continue
+		}
+		start, err := parseICSTime(e.Start, day.Location())
+		if err != nil {
+			return nil, fmt.Errorf("ParseICS: event %q: %w", e.Summary, err)
+		}
+		length, err := e.length(start, day.Location())
+		if err != nil {
+			return nil, fmt.Errorf("ParseICS: event %q: %w", e.Summary, err)
+		}
+
+		starts := []time.Time{start}
+		if e.RRule != "" && e.RecurrenceID == nil {
+			rule, err := parseRRule(e.RRule, start.Location())
+			if err != nil {
+				return nil, fmt.Errorf("ParseICS: event %q: %w", e.Summary, err)
+			}
+			exdates, err := e.exDates(day.Location())
+			if err != nil {
+				return nil, fmt.Errorf("ParseICS: event %q: %w", e.Summary, err)
+			}
+			starts = nil
+			for _, s := range rule.occurrencesNear(start, day) {
+				key := s.UTC().Format(time.RFC3339)
+				if !exdates[key] && !overridden[e.UID+"/"+key] {
+					starts = append(starts, s)
+				}
+			}
+		}
+
+		for _, s := range starts {
+			s = s.In(day.Location())
+			if s.Before(day) || !s.Before(day.AddDate(0, 0, 1)) || length < 5*time.Minute {
+				continue
+			}
+			task := NewTask(e.Summary, s, s.Add(length)).WithTag(e.Categories).AsFixed(true)
+			if task.IsEmpty() {
+				continue
+			}
+			tasks = append(tasks, task)
+		}
+	}
+
+	return tasks, nil
+}
+
+// readICSEvents returns the VEVENTs in an iCalendar file.
+func readICSEvents(r io.Reader) ([]icalEvent, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []icalEvent
+	var current *icalEvent
+	depth := 0 // Depth of components nested inside the current VEVENT
+	for n, line := range lines {
+		p, err := parseICSLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		switch {
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VEVENT") && current == nil:
+			current = &icalEvent{}
+		case current == nil:
+			continue
+		case p.Name == "BEGIN":
+			depth++
+		case p.Name == "END" && depth > 0:
+			depth--
+		case p.Name == "END" && strings.EqualFold(p.Value, "VEVENT"):
+			events = append(events, *current)
+			current = nil
+		case depth > 0:
+			continue // e.g. a VALARM's properties
+		case p.Name == "UID":
+			current.UID = p.Value
+		case p.Name == "SUMMARY":
+			current.Summary = unescapeICS(p.Value)
+		case p.Name == "CATEGORIES":
+			if current.Categories == "" {
+				current.Categories = strings.TrimSpace(unescapeICS(splitICSList(p.Value)[0]))
+			}
+		case p.Name == "STATUS":
+			current.Status = p.Value
+		case p.Name == "DTSTART":
+			current.Start = &p
+		case p.Name == "DTEND":
+			current.End = &p
+		case p.Name == "DURATION":
+			current.Duration = p.Value
+		case p.Name == "RRULE":
+			current.RRule = p.Value
+		case p.Name == "RECURRENCE-ID":
+			current.RecurrenceID = &p
+		case p.Name == "EXDATE":
+			current.ExDates = append(current.ExDates, &p)
+		}
+	}
+	if current != nil {
+		return nil, InvalidScheduleError{"Calendar ends inside a VEVENT."}
+	}
+
+	return events, nil
+}
+
+// unfoldICS reads the content lines of an iCalendar file,
+// joining lines that were folded onto several lines.
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseICSLine splits a content line into its name,
+// parameters, and value.
+func parseICSLine(line string) (icalProperty, error) {
+	p := icalProperty{Params: make(map[string]string)}
+
+	// The value starts at the first colon that isn't quoted
+	inQuotes := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			inQuotes = !inQuotes
+		} else if c == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon == -1 {
+		return p, InvalidScheduleError{"Calendar line has no value: " + line}
+	}
+	p.Value = line[colon+1:]
+
+	fields := strings.Split(line[:colon], ";")
+	p.Name = strings.ToUpper(fields[0])
+	for _, param := range fields[1:] {
+		key, value, _ := strings.Cut(param, "=")
+		p.Params[strings.ToUpper(key)] = strings.Trim(value, "\"")
+	}
+
+	return p, nil
+}
+
+// splitICSList splits a comma-separated value,
+// ignoring escaped commas.
+func splitICSList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' {
+			i++
+		} else if value[i] == ',' {
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+// unescapeICS replaces the escape sequences in a text value.
+func unescapeICS(value string) string {
+	return strings.NewReplacer(
+		`\\`, `\`,
+		`\;`, `;`,
+		`\,`, `,`,
+		`\n`, "\n",
+		`\N`, "\n",
+	).Replace(value)
+}
+
+// parseICSTime parses a DATE-TIME property. UTC times are given
+// with a Z suffix, times in a zone have a TZID parameter, and any
+// other times are floating, so they are read in the given location.
+func parseICSTime(p *icalProperty, floating *time.Location) (time.Time, error) {
+	return parseICSTimeValue(p.Value, p.Params["TZID"], floating)
+}
+
+func parseICSTimeValue(value, tzid string, floating *time.Location) (time.Time, error) {
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse("20060102T150405Z", value)
+	}
+	loc := floating
+	if tzid != "" {
+		var err error
+		loc, err = time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, InvalidTimeError{"Unknown time zone " + tzid + "."}
+		}
+	}
+	if len(value) == 8 {
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+// length returns how long each occurrence of the event lasts.
+func (e icalEvent) length(start time.Time, floating *time.Location) (time.Duration, error) {
+	if e.End != nil {
+		end, err := parseICSTime(e.End, floating)
+		if err != nil {
+			return 0, err
+		}
+		return end.Sub(start), nil
+	}
+	if e.Duration != "" {
+		return parseICSDuration(e.Duration)
+	}
+	return 0, nil
+}
+
+// exDates returns the set of excluded occurrences of the
+// event, keyed by their start time in UTC.
+func (e icalEvent) exDates(floating *time.Location) (map[string]bool, error) {
+	excluded := make(map[string]bool)
+	for _, p := range e.ExDates {
+		for _, value := range splitICSList(p.Value) {
+			t, err := parseICSTimeValue(value, p.Params["TZID"], floating)
+			if err != nil {
+				return nil, err
+			}
+			excluded[t.UTC().Format(time.RFC3339)] = true
+		}
+	}
+	return excluded, nil
+}
+
+// parseICSDuration parses a DURATION value, e.g. PT1H30M or P1D.
+func parseICSDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	if strings.HasPrefix(value, "-") {
+		return 0, InvalidTimeError{"Negative durations are not supported."}
+	}
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, InvalidTimeError{"Invalid duration " + value + "."}
+		}
+		num = ""
+		switch {
+		case c == 'W':
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case c == 'D':
+			d += time.Duration(n) * 24 * time.Hour
+		case c == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case c == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case c == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, InvalidTimeError{"Invalid duration " + value + "."}
+		}
+	}
+	if num != "" {
+		return 0, InvalidTimeError{"Invalid duration " + value + "."}
+	}
+	return d, nil
+}
+
+// icsUID returns the UID of the event for the given task. Breaks
+// are given new IDs whenever they are rebuilt, so a break's UID is
+// derived from its start time instead, which is stable as long as
+// the break itself is.
+func icsUID(t *Task) string {
+	if t.IsBreak() {
+		return "break-" + formatICSTime(t.StartTime) + "@timeruler"
+	}
+	return t.ID + "@timeruler"
+}
+
+// icsProductID identifies timeruler as the producer of
+// exported calendars.
+const icsProductID = "-//timeruler//timeruler//EN"
+
+// WriteICS writes the schedule's tasks, including breaks, as an
+// iCalendar (RFC 5545) VCALENDAR.
+func (s *Schedule) WriteICS(w io.Writer) error {
+	return s.Tasks.WriteICS(w, s.now())
+}
+
+// WriteICS writes the tasks as an iCalendar (RFC 5545) VCALENDAR
+// with one VEVENT per task. Each event's UID is derived from the
+// task's ID, so calendar clients that subscribe to the schedule
+// update the same event when the task changes; see icsUID. The
+// stamp is used as every event's DTSTAMP.
+func (tl TaskList) WriteICS(w io.Writer, stamp time.Time) error {
+	bw := bufio.NewWriter(w)
+	writeICSLine(bw, "BEGIN:VCALENDAR")
+	writeICSLine(bw, "VERSION:2.0")
+	writeICSLine(bw, "PRODID:"+icsProductID)
+	writeICSLine(bw, "CALSCALE:GREGORIAN")
+	for _, t := range tl {
+		writeICSLine(bw, "BEGIN:VEVENT")
+		writeICSLine(bw, "UID:"+icsUID(t))
+		writeICSLine(bw, "DTSTAMP:"+formatICSTime(stamp))
+		writeICSLine(bw, "DTSTART:"+formatICSTime(t.StartTime))
+		writeICSLine(bw, "DTEND:"+formatICSTime(t.EndTime))
+		writeICSLine(bw, "SUMMARY:"+escapeICS(t.Description))
+		if t.Tag != "" {
+			writeICSLine(bw, "CATEGORIES:"+escapeICS(t.Tag))
+		}
+		if t.IsBreak() {
+			writeICSLine(bw, "TRANSP:TRANSPARENT")
+		}
+		writeICSLine(bw, "END:VEVENT")
+	}
+	writeICSLine(bw, "END:VCALENDAR")
+
+	err := bw.Flush()
+	if err != nil {
+		return fmt.Errorf("WriteICS: %w", err)
+	}
+	return nil
+}
+
+// writeICSLine writes a content line, folding it so that no
+// line is longer than 75 octets. Lines are only folded between
+// UTF-8 characters.
+func writeICSLine(w *bufio.Writer, line string) {
+	const limit = 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n")
+		// The continuation line starts with a space, so it
+		// has room for one octet less.
+		line = " " + line[cut:]
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// escapeICS escapes the characters that have a special
+// meaning in a text value.
+func escapeICS(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`;`, `\;`,
+		`,`, `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(value)
+}
+
+// formatICSTime formats t as a UTC DATE-TIME value.
+func formatICSTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseICS(t *testing.T) {
+	f, err := os.Open("./test_data/calendar.ics")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer f.Close()
+
+	day := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+	tasks, err := ParseICS(f, day)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	for _, task := range tasks {
+		if !task.Fixed {
+			t.Fatalf("Expected tasks from a calendar to be fixed: %v", task)
+		}
+	}
+	tl, err := NewTaskList(tasks...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sched := NewSchedule(tl, ScheduleOptions{})
+	sched.CurrentID = -1
+
+	// New York is on daylight saving time from 10 March 2024,
+	// so 08:00 there is 12:00 UTC.
+	expected := `
+	[07:00:00-07:30:00] Morning review of the previous day's work and planning for the rest of the week ()
+	[07:30:00-12:00:00] Break (break)
+	[12:00:00-13:00:00] Call with New York, part 1 ()
+	[13:00:00-13:30:00] Late lunch (food)
+	[13:30:00-15:00:00] Break (break)
+	[15:00:00-15:15:00] Standup (meeting)`
+	got := strings.Join(strings.Fields(sched.String()), "")
+	if strings.Join(strings.Fields(expected), "") != got {
+		t.Fatalf("Expected: %s\n Got: %s", expected, sched.String())
+	}
+}
+
+func TestParseRRule(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rule  string
+		day   time.Time
+		occur bool
+	}{
+		{"FREQ=DAILY", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC), true},
+		{"FREQ=DAILY;UNTIL=20240201", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC), false},
+		{"FREQ=DAILY;UNTIL=20240202", time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC), true},
+		{"FREQ=WEEKLY;INTERVAL=2", time.Date(2024, time.February, 7, 0, 0, 0, 0, time.UTC), false},
+		{"FREQ=WEEKLY;INTERVAL=2", time.Date(2024, time.February, 14, 0, 0, 0, 0, time.UTC), true},
+		{"FREQ=WEEKLY;BYDAY=TU,TH", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC), true},
+		{"FREQ=MONTHLY", time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC), true},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15", time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC), true},
+		{"FREQ=YEARLY", time.Date(2025, time.January, 31, 0, 0, 0, 0, time.UTC), true},
+		{"FREQ=YEARLY", time.Date(2025, time.January, 30, 0, 0, 0, 0, time.UTC), false},
+	}
+	for _, test := range tests {
+		r, err := parseRRule(test.rule, time.UTC)
+		if err != nil {
+			t.Fatalf("%s: %s", test.rule, err.Error())
+		}
+		occurs := false
+		for _, s := range r.occurrencesNear(start, test.day) {
+			if !s.Before(test.day) && s.Before(test.day.AddDate(0, 0, 1)) {
+				occurs = true
+			}
+		}
+		if occurs != test.occur {
+			t.Fatalf("%s on %s: expected %t, got %t", test.rule, test.day.Format(time.DateOnly), test.occur, occurs)
+		}
+	}
+
+	_, err := parseRRule("FREQ=MONTHLY;BYDAY=2MO", time.UTC)
+	if err == nil {
+		t.Fatalf("Expected error for an unsupported rule")
+	}
+	_, err = parseRRule("FREQ=HOURLY", time.UTC)
+	if err == nil {
+		t.Fatalf("Expected error for an unsupported frequency")
+	}
+}
+
+func TestBuildFromICSFile(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 12, 30, 0, 0, time.UTC))
+	sched, err := BuildFromFile("./test_data/calendar.ics", ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.CurrentTask == nil || sched.CurrentTask.Description != "Call with New York, part 1" {
+		t.Fatalf("Expected current task to be the New York call, got: %v", sched.CurrentTask)
+	}
+}
+
+func TestWriteICS(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC))
+	now := c.Now()
+	tl, err := NewTaskList(
+		NewTask("Write the report; then, send it", now.Add(time.Hour), now.Add(2*time.Hour)).WithTag("work"),
+		NewTask(strings.Repeat("A long description ", 5), now.Add(3*time.Hour), now.Add(4*time.Hour)),
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sched := NewSchedule(tl, ScheduleOptions{Clock: c})
+
+	var buf strings.Builder
+	err = sched.WriteICS(&buf)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	out := buf.String()
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("Expected lines of at most 75 octets, got: %q", line)
+		}
+	}
+	for _, task := range sched.Tasks.WithoutBreaks() {
+		if !strings.Contains(out, "UID:"+task.ID+"@timeruler\r\n") {
+			t.Fatalf("Expected UID for task %s in:\n%s", task.ID, out)
+		}
+	}
+	// A break's UID doesn't change when the breaks are rebuilt
+	breakUID := "UID:break-20240310T100000Z@timeruler\r\n"
+	if !strings.Contains(out, breakUID) {
+		t.Fatalf("Expected UID for the break in:\n%s", out)
+	}
+	rebuiltList, err := NewTaskList(sched.Tasks.WithoutBreaks().values()...)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if rebuiltList[1].ID == sched.Tasks[1].ID {
+		t.Fatalf("Expected the rebuilt break to have a new ID")
+	}
+	var rebuilt strings.Builder
+	err = rebuiltList.WriteICS(&rebuilt, now)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !strings.Contains(rebuilt.String(), breakUID) {
+		t.Fatalf("Expected the rebuilt break to keep its UID in:\n%s", rebuilt.String())
+	}
+
+	// Exporting and importing the schedule should give the same tasks.
+	tasks, err := ParseICS(strings.NewReader(out), now)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(tasks) != len(sched.Tasks) {
+		t.Fatalf("Expected %d tasks, got %d", len(sched.Tasks), len(tasks))
+	}
+	for i, task := range tasks {
+		expected := sched.Tasks.get(i)
+		if task.Description != expected.Description || task.Tag != expected.Tag ||
+			!task.StartTime.Equal(expected.StartTime) || !task.EndTime.Equal(expected.EndTime) {
+			t.Fatalf("Expected: %s\n Got: %s", expected.String(), task.String())
+		}
+	}
+
+	buf.Reset()
+	err = sched.Tasks.WithoutBreaks().WriteICS(&buf, now)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if strings.Count(buf.String(), "BEGIN:VEVENT") != 2 {
+		t.Fatalf("Expected 2 events without breaks, got:\n%s", buf.String())
+	}
+}
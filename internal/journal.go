@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"time"
+)
+
+// JournalEntry records a single change to a schedule's task
+// list as the tasks the change removed and the tasks it added.
+type JournalEntry struct {
+	Op      string    `json:"Op"`
+	Time    time.Time `json:"Time"`
+	Removed []Task    `json:"Removed"`
+	Added   []Task    `json:"Added"`
+}
+
+// Journal is an append-only log of the changes made to a
+// schedule. Undoing or redoing a change appends a new entry
+// rather than rewriting the log.
+type Journal struct {
+	Entries []JournalEntry
+
+	undo []int // Indices of entries that can be undone
+	redo []int // Indices of entries that can be redone
+}
+
+// add appends a new change to the journal. A new change
+// cannot be followed by a redo of an older one.
+func (j *Journal) add(e JournalEntry) {
+	j.Entries = append(j.Entries, e)
+	j.undo = append(j.undo, len(j.Entries)-1)
+	j.redo = nil
+}
+
+// savedJournal is the on-disk representation of a Journal.
+type savedJournal struct {
+	Entries []JournalEntry `json:"Entries"`
+	Undo    []int          `json:"Undo"`
+	Redo    []int          `json:"Redo"`
+}
+
+// saved returns the on-disk representation of the journal.
+func (j *Journal) saved() savedJournal {
+	return savedJournal{Entries: j.Entries, Undo: j.undo, Redo: j.redo}
+}
+
+// restoreJournal returns the journal saved as sj. A journal whose
+// undo or redo stack names an entry it doesn't have is discarded,
+// since it can't have been saved by this package.
+func restoreJournal(sj savedJournal) Journal {
+	for _, idx := range append(append([]int{}, sj.Undo...), sj.Redo...) {
+		if idx < 0 || idx >= len(sj.Entries) {
+			return Journal{}
+		}
+	}
+	return Journal{Entries: sj.Entries, undo: sj.Undo, redo: sj.Redo}
+}
+
+// clone returns a copy of the journal that can be
+// added to without changing j.
+func (j *Journal) clone() Journal {
+	return Journal{
+		Entries: append([]JournalEntry{}, j.Entries...),
+		undo:    append([]int{}, j.undo...),
+		redo:    append([]int{}, j.redo...),
+	}
+}
+
+// CanUndo returns true if there is a change that can be undone.
+func (j *Journal) CanUndo() bool {
+	return len(j.undo) > 0
+}
+
+// CanRedo returns true if there is an undone change that can be redone.
+func (j *Journal) CanRedo() bool {
+	return len(j.redo) > 0
+}
+
+// diffTasks returns the tasks in before that are not in after,
+// and the tasks in after that are not in before.
+func diffTasks(before, after []Task) (removed, added []Task) {
+	for _, b := range before {
+		if indexOfTask(after, b) == -1 {
+			removed = append(removed, b)
+		}
+	}
+	for _, a := range after {
+		if indexOfTask(before, a) == -1 {
+			added = append(added, a)
+		}
+	}
+	return removed, added
+}
+
+// indexOfTask returns the index of the task in tasks equal to t,
+// or -1 if there is none.
+func indexOfTask(tasks []Task, t Task) int {
+	for i := range tasks {
+		if tasks[i].sameAs(t) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyDiff returns a new TaskList built from tasks with the
+// removed tasks taken out and the added tasks put in. It returns
+// an error if a task to be removed can't be found or if the
+// result contains a conflict.
+func applyDiff(tasks []Task, removed, added []Task) (TaskList, error) {
+	result := append([]Task{}, tasks...)
+	for _, r := range removed {
+		idx := indexOfTask(result, r)
+		if idx == -1 {
+			return nil, InvalidScheduleError{"Schedule no longer matches the journal."}
+		}
+		result = append(result[:idx], result[idx+1:]...)
+	}
+	result = append(result, added...)
+
+	tl := make(TaskList, len(result))
+	for i := range result {
+		tl[i] = &result[i]
+	}
+	tl.sort()
+	if !tl.IsConsistent() {
+		return nil, InvalidScheduleError{"Change would leave the schedule with a conflict."}
+	}
+
+	return tl, nil
+}
+
+// record runs the given change against the schedule and adds
+// the difference it made to the journal under the given name.
+// If the change fails, the schedule's tasks are restored to
+// what they were before it ran.
+func (s *Schedule) record(op string, change func() error) error {
+	before := s.Tasks.values()
+	err := change()
+	if err != nil {
+		s.Tasks = TaskList{}
+		for i := range before {
+			s.Tasks = append(s.Tasks, &before[i])
+		}
+		return err
+	}
+
+	removed, added := diffTasks(before, s.Tasks.values())
+	if len(removed) == 0 && len(added) == 0 {
+		return nil
+	}
+	s.Journal.add(JournalEntry{
+		Op:      op,
+		Time:    s.now(),
+		Removed: removed,
+		Added:   added,
+	})
+
+	return nil
+}
+
+// Undo reverts the most recent change to the schedule that
+// has not already been undone. It returns an EmptyJournalError
+// if there is nothing to undo.
+func (s *Schedule) Undo() error {
+	if !s.Journal.CanUndo() {
+		return EmptyJournalError{"Nothing to undo."}
+	}
+	idx := s.Journal.undo[len(s.Journal.undo)-1]
+	e := s.Journal.Entries[idx]
+
+	tl, err := applyDiff(s.Tasks.values(), e.Added, e.Removed)
+	if err != nil {
+		return err
+	}
+	s.Tasks = tl
+	s.Journal.Entries = append(s.Journal.Entries, JournalEntry{
+		Op:      "undo",
+		Time:    s.now(),
+		Removed: e.Added,
+		Added:   e.Removed,
+	})
+	s.Journal.undo = s.Journal.undo[:len(s.Journal.undo)-1]
+	s.Journal.redo = append(s.Journal.redo, idx)
+
+	return nil
+}
+
+// Redo reapplies the most recently undone change to the
+// schedule. It returns an EmptyJournalError if there is
+// nothing to redo.
+func (s *Schedule) Redo() error {
+	if !s.Journal.CanRedo() {
+		return EmptyJournalError{"Nothing to redo."}
+	}
+	idx := s.Journal.redo[len(s.Journal.redo)-1]
+	e := s.Journal.Entries[idx]
+
+	tl, err := applyDiff(s.Tasks.values(), e.Removed, e.Added)
+	if err != nil {
+		return err
+	}
+	s.Tasks = tl
+	s.Journal.Entries = append(s.Journal.Entries, JournalEntry{
+		Op:      "redo",
+		Time:    s.now(),
+		Removed: e.Removed,
+		Added:   e.Added,
+	})
+	s.Journal.redo = s.Journal.redo[:len(s.Journal.redo)-1]
+	s.Journal.undo = append(s.Journal.undo, idx)
+
+	return nil
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestUndoRedo(t *testing.T) {
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	original := sched.String()
+
+	err = sched.Undo()
+	if !errors.As(err, &EmptyJournalError{}) {
+		t.Fatalf("Expected EmptyJournalError when undoing with no changes, got: %v", err)
+	}
+
+	nTask := NewTask("Nap", sched.Tasks[1].StartTime.Add(1*time.Hour), sched.Tasks[1].EndTime.Add(-1*time.Hour))
+	err = sched.UpdateTimeBlock(nTask)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	updated := sched.String()
+	if updated == original {
+		t.Fatalf("UpdateTimeBlock should have changed the schedule")
+	}
+
+	err = sched.Undo()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.String() != original {
+		t.Fatalf("Expected: %s\n Got: %s", original, sched.String())
+	}
+
+	err = sched.Redo()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.String() != updated {
+		t.Fatalf("Expected: %s\n Got: %s", updated, sched.String())
+	}
+
+	err = sched.Redo()
+	if !errors.As(err, &EmptyJournalError{}) {
+		t.Fatalf("Expected EmptyJournalError when redoing with nothing undone, got: %v", err)
+	}
+	if len(sched.Journal.Entries) != 3 {
+		t.Fatalf("Expected 3 journal entries, got %d", len(sched.Journal.Entries))
+	}
+
+	// A new change after an undo discards the redo history
+	err = sched.Undo()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	nTask = NewTask("Read", sched.Tasks[3].StartTime, sched.Tasks[3].StartTime.Add(30*time.Minute))
+	err = sched.AddTask(nTask)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.Journal.CanRedo() {
+		t.Fatalf("Redo should not be possible after a new change")
+	}
+	err = sched.Undo()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.String() != original {
+		t.Fatalf("Expected: %s\n Got: %s", original, sched.String())
+	}
+}
+
+func TestRecordRestoresOnError(t *testing.T) {
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	original := sched.String()
+
+	valid := NewTask("Nap", sched.Tasks[1].StartTime, sched.Tasks[1].StartTime.Add(30*time.Minute))
+	invalid := NewTask("Tomorrow", valid.StartTime.AddDate(0, 0, 1), valid.EndTime.AddDate(0, 0, 1))
+	err = sched.UpdateTimeBlock(valid, invalid)
+	if err == nil {
+		t.Fatalf("Expected error when updating with a task on another day")
+	}
+	if sched.String() != original {
+		t.Fatalf("Failed update should leave schedule unchanged.\nExpected: %s\n Got: %s", original, sched.String())
+	}
+	if sched.Journal.CanUndo() {
+		t.Fatalf("Failed update should not be recorded")
+	}
+}
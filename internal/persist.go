@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// savedSchedule is the on-disk representation of a Schedule.
+// The journal is kept with the tasks, so that changes can still
+// be undone after a restart.
+type savedSchedule struct {
+	Date      string       `json:"Date"`
+	Tasks     []Task       `json:"Tasks"`
+	CurrentID int          `json:"CurrentID"`
+	Journal   savedJournal `json:"Journal"`
+}
+
+// SaveToFile writes the schedule as JSON to the file with the given
+// name, recording the given day as the day the schedule belongs to.
+// It is written with WriteFileAtomic, so a crash mid-write never
+// leaves a truncated schedule behind.
+func (s *Schedule) SaveToFile(fileName string, day time.Time) error {
+	saved := savedSchedule{
+		Date:      day.Format(time.DateOnly),
+		Tasks:     make([]Task, len(s.Tasks)),
+		CurrentID: s.CurrentID,
+		Journal:   s.Journal.saved(),
+	}
+	for i := range s.Tasks {
+		saved.Tasks[i] = *s.Tasks[i]
+	}
+	payload, err := json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		return fmt.Errorf("SaveToFile: %w", err)
+	}
+
+	err = WriteFileAtomic(fileName, payload, 0600)
+	if err != nil {
+		return fmt.Errorf("SaveToFile: %w", err)
+	}
+
+	return nil
+}
+
+// WriteFileAtomic writes data to the file with the given name, as
+// os.WriteFile does, but writes it to a temporary file in the same
+// directory first and then renames it. The file is either left as
+// it was or replaced whole, never truncated.
+func WriteFileAtomic(fileName string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fileName), filepath.Base(fileName)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// ReadFromFile reads a schedule written by SaveToFile, whatever
+// day it was saved for. The schedule's Day is midnight at the
+// start of that day in the given location, and the day begins
+// at dayStart after midnight. Callers check Day to tell whether
+// the schedule is for the day they expect.
+func ReadFromFile(fileName string, loc *time.Location, dayStart time.Duration) (*Schedule, error) {
+	payload, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFromFile: %w", err)
+	}
+
+	var saved savedSchedule
+	err = json.Unmarshal(payload, &saved)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFromFile: %w", err)
+	}
+	day, err := time.ParseInLocation(time.DateOnly, saved.Date, loc)
+	if err != nil {
+		return nil, fmt.Errorf("ReadFromFile: %w", err)
+	}
+
+	tl := make(TaskList, len(saved.Tasks))
+	for i := range saved.Tasks {
+		if saved.Tasks[i].ID == "" {
+			saved.Tasks[i].ID = NewTaskID()
+		}
+		tl[i] = &saved.Tasks[i]
+	}
+	if !tl.IsConsistent() {
+		return nil, InvalidScheduleError{"Saved schedule contains a conflict."}
+	}
+
+	s := &Schedule{
+		Tasks:     tl,
+		CurrentID: -1,
+		Day:       day,
+		DayStart:  dayStart,
+		Journal:   restoreJournal(saved.Journal),
+	}
+	if saved.CurrentID >= 0 && saved.CurrentID < len(tl) {
+		s.CurrentTask = tl[saved.CurrentID]
+		s.CurrentID = saved.CurrentID
+	}
+
+	return s, nil
+}
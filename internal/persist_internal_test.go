@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sched.CurrentID = 2
+	sched.CurrentTask = sched.Tasks[2]
+
+	fileName := filepath.Join(t.TempDir(), "schedule.json")
+	today := time.Now()
+	err = sched.SaveToFile(fileName, today)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	loaded, err := ReadFromFile(fileName, today.Location(), 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if !loaded.Day.Equal(DayOf(today, 0)) {
+		t.Fatalf("Expected the schedule to be for %v, got %v", DayOf(today, 0), loaded.Day)
+	}
+	if loaded.String() != sched.String() {
+		t.Fatalf("Expected: %s\n Got: %s", sched.String(), loaded.String())
+	}
+	if loaded.CurrentTask == nil || loaded.CurrentTask.Description != "Eat Lunch" {
+		t.Fatalf("Expected current task to be restored, got: %v", loaded.CurrentTask)
+	}
+	for i := range loaded.Tasks {
+		if !loaded.Tasks[i].StartTime.Equal(sched.Tasks[i].StartTime) ||
+			!loaded.Tasks[i].EndTime.Equal(sched.Tasks[i].EndTime) {
+			t.Fatalf("Expected: %s, Got: %s", sched.Tasks[i].String(), loaded.Tasks[i].String())
+		}
+	}
+
+	_, err = ReadFromFile(filepath.Join(t.TempDir(), "missing.json"), today.Location(), 0)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Expected os.ErrNotExist for missing file, got: %v", err)
+	}
+}
+
+func TestSaveJournal(t *testing.T) {
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	original := sched.String()
+	err = sched.UpdateTimeBlock(NewTask("Nap", sched.Tasks[1].StartTime.Add(time.Hour), sched.Tasks[1].StartTime.Add(2*time.Hour)))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	fileName := filepath.Join(t.TempDir(), "schedule.json")
+	today := time.Now()
+	err = sched.SaveToFile(fileName, today)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	loaded, err := ReadFromFile(fileName, today.Location(), 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(loaded.Journal.Entries) != 1 {
+		t.Fatalf("Expected the journal to be restored, got %+v", loaded.Journal)
+	}
+	err = loaded.Undo()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if loaded.String() != original {
+		t.Fatalf("Expected: %s\n Got: %s", original, loaded.String())
+	}
+	if !loaded.Journal.CanRedo() {
+		t.Fatalf("Expected the undone change to be redoable")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "plan.json")
+	for _, data := range []string{"first", "second"} {
+		err := WriteFileAtomic(fileName, []byte(data), 0644)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		got, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		if string(got) != data {
+			t.Fatalf("Expected %q, got %q", data, got)
+		}
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Fatalf("Expected mode 0644, got %v", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected no temporary files to be left behind, got %d files", len(entries))
+	}
+
+	err = WriteFileAtomic(filepath.Join(dir, "missing", "plan.json"), []byte("data"), 0644)
+	if err == nil {
+		t.Fatalf("Expected an error writing to a missing directory")
+	}
+}
@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration that is written as text,
+// such as 1h30m0s, rather than as a number of nanoseconds.
+type Duration time.Duration
+
+// MarshalText writes the duration as text.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText reads a duration such as 45m or 1h30m.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return InvalidTimeError{fmt.Sprintf("%q is not a duration such as 45m or 1h30m.", text)}
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// BacklogTask is a task that hasn't been given a time yet.
+// Tasks with a higher priority are planned first.
+type BacklogTask struct {
+	ID            string     `json:"ID"`
+	Description   string     `json:"Description"`
+	Tag           string     `json:"Tag"`
+	Estimate      Duration   `json:"Estimate"`
+	Priority      int        `json:"Priority"`
+	Deadline      *time.Time `json:"Deadline"`      // Time by which the task must end, if any
+	EarliestStart *time.Time `json:"EarliestStart"` // Time before which the task can't start, if any
+}
+
+// Validate checks that the task has a description and an
+// estimate of at least 5 minutes, and that it can be done
+// between its earliest start and its deadline.
+func (b BacklogTask) Validate() error {
+	if strings.TrimSpace(b.Description) == "" {
+		return InvalidScheduleError{"Task has no description."}
+	}
+	if time.Duration(b.Estimate) < 5*time.Minute {
+		return InvalidTimeError{"Task's estimate must be at least 5 minutes."}
+	}
+	if b.Deadline != nil && b.EarliestStart != nil &&
+		b.EarliestStart.Add(time.Duration(b.Estimate)).After(*b.Deadline) {
+		return InvalidTimeError{"Task can't be done between its earliest start and its deadline."}
+	}
+	return nil
+}
+
+// Proposal is a schedule proposed by PlanBacklog. Planned holds
+// the backlog tasks that were given a time, with their IDs kept,
+// and Unplanned those that didn't fit.
+type Proposal struct {
+	Schedule  *Schedule
+	Planned   []Task
+	Unplanned []BacklogTask
+}
+
+// PlanBacklog proposes a schedule with the backlog's tasks packed
+// into the breaks in the given schedule that haven't passed yet.
+// The schedule itself is left unchanged.
+//
+// Tasks are planned in order of priority, then of deadline, and
+// each is put in the earliest time it fits that is after its
+// earliest start and before its deadline. Estimates are rounded
+// up to 5 minutes, as tasks are quantized.
+func PlanBacklog(s *Schedule, backlog []BacklogTask) (Proposal, error) {
+	order := make([]BacklogTask, len(backlog))
+	copy(order, backlog)
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].Priority != order[j].Priority {
+			return order[i].Priority > order[j].Priority
+		}
+		if order[j].Deadline == nil {
+			return order[i].Deadline != nil
+		}
+		return order[i].Deadline != nil && order[i].Deadline.Before(*order[j].Deadline)
+	})
+
+	now := roundUp(s.now())
+	var gaps []gap
+	for _, t := range s.Tasks {
+		if !t.IsBreak() || !t.EndTime.After(now) {
+			continue
+		}
+		g := gap{t.StartTime, t.EndTime}
+		if g.start.Before(now) {
+			g.start = now
+		}
+		gaps = append(gaps, g)
+	}
+
+	p := Proposal{Schedule: s.Clone(), Planned: []Task{}, Unplanned: []BacklogTask{}}
+	for _, b := range order {
+		if err := b.Validate(); err != nil {
+			return Proposal{}, fmt.Errorf("PlanBacklog: %s: %w", b.Description, err)
+		}
+		estimate := (time.Duration(b.Estimate) + 5*time.Minute - 1).Truncate(5 * time.Minute)
+
+		placed := false
+		for i, g := range gaps {
+			start := g.start
+			if b.EarliestStart != nil && b.EarliestStart.After(start) {
+				start = roundUp(*b.EarliestStart)
+			}
+			end := start.Add(estimate)
+			if end.After(g.end) || (b.Deadline != nil && end.After(*b.Deadline)) {
+				continue
+			}
+
+			task := Task{ID: b.ID, Description: b.Description, StartTime: start, EndTime: end, Tag: b.Tag}
+			if task.ID == "" {
+				task.ID = NewTaskID()
+			}
+			p.Planned = append(p.Planned, task)
+			gaps = append(gaps[:i], append([]gap{{g.start, start}, {end, g.end}}, gaps[i+1:]...)...)
+			placed = true
+			break
+		}
+		if !placed {
+			p.Unplanned = append(p.Unplanned, b)
+		}
+	}
+
+	err := p.Schedule.record("plan", func() error {
+		_, err := p.Schedule.updateTimeBlock(Split{}, p.Planned...)
+		return err
+	})
+	if err != nil {
+		return Proposal{}, fmt.Errorf("PlanBacklog: %w", err)
+	}
+	p.Schedule.UpdateCurrentTask()
+	return p, nil
+}
+
+// Token identifies the proposal by its schedule and the tasks that
+// didn't fit, so that it can be checked that a later proposal is the
+// same. Breaks are left out, since they are given new IDs each time.
+func (p Proposal) Token() string {
+	h := sha256.New()
+	for _, t := range p.Schedule.Tasks {
+		if t.IsBreak() {
+			continue
+		}
+		fmt.Fprintf(h, "%s|%s|%s|%d|%d|%t\n", t.ID, t.Description, t.Tag,
+			t.StartTime.Unix(), t.EndTime.Unix(), t.Fixed)
+	}
+	for _, b := range p.Unplanned {
+		fmt.Fprintf(h, "%s\n", b.ID)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// gap is a stretch of free time in a schedule.
+type gap struct {
+	start, end time.Time
+}
+
+// roundUp rounds t up to the next 5-minute increment.
+func roundUp(t time.Time) time.Time {
+	rounded := t.Truncate(5 * time.Minute)
+	if rounded.Before(t) {
+		rounded = rounded.Add(5 * time.Minute)
+	}
+	return rounded
+}
@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPlanBacklog(t *testing.T) {
+	day := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+	at := func(hour, min int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+	}
+	c := NewFakeClock(at(12, 0))
+	tl, err := NewTaskList(
+		NewTask("Work", at(9, 0), at(12, 30)),
+		NewTask("Lunch", at(13, 30), at(14, 0)),
+		NewTask("Meeting", at(15, 0), at(16, 0)),
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	sched := NewSchedule(tl, ScheduleOptions{Clock: c})
+
+	input := fmt.Sprintf(`[
+		{"ID": "a", "Description": "Write", "Estimate": "45m", "Priority": 2},
+		{"ID": "b", "Description": "Call", "Estimate": "30m", "Priority": 1, "Deadline": %q},
+		{"ID": "c", "Description": "Read", "Estimate": "20m", "Priority": 1, "EarliestStart": %q},
+		{"ID": "d", "Description": "Email", "Estimate": "12m"},
+		{"ID": "e", "Description": "Project", "Estimate": "2h"}
+	]`, at(13, 30).Format(time.RFC3339), at(14, 10).Format(time.RFC3339))
+	var backlog []BacklogTask
+	err = json.Unmarshal([]byte(input), &backlog)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	p, err := PlanBacklog(&sched, backlog)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	token := p.Token()
+	var planned []string
+	for _, task := range p.Planned {
+		planned = append(planned, fmt.Sprintf("%s %s-%s", task.ID,
+			task.StartTime.Format("15:04"), task.EndTime.Format("15:04")))
+	}
+	if fmt.Sprint(planned) != "[a 12:30-13:15 c 14:10-14:30 d 13:15-13:30]" {
+		t.Fatalf("Unexpected plan: %v", planned)
+	}
+	var unplanned []string
+	for _, b := range p.Unplanned {
+		unplanned = append(unplanned, b.ID)
+	}
+	if fmt.Sprint(unplanned) != "[b e]" {
+		t.Fatalf("Expected b and e not to fit, got %v", unplanned)
+	}
+
+	var desc []string
+	for _, task := range p.Schedule.Tasks {
+		desc = append(desc, task.Description)
+	}
+	if fmt.Sprint(desc) != "[Work Write Email Lunch Break Read Break Meeting]" {
+		t.Fatalf("Unexpected proposed schedule: %v", desc)
+	}
+	if len(sched.Tasks) != 5 {
+		t.Fatalf("Expected the schedule to be unchanged, got %v", sched.Tasks)
+	}
+	err = p.Schedule.Undo()
+	if err != nil || len(p.Schedule.Tasks) != 5 {
+		t.Fatalf("Expected the plan to be undoable, got %v: %v", err, p.Schedule.Tasks)
+	}
+
+	again, err := PlanBacklog(&sched, backlog)
+	if err != nil || again.Token() != token {
+		t.Fatalf("Expected the same plan to have the same token, got %v", err)
+	}
+
+	// Time that has passed isn't planned
+	c.Set(at(14, 20))
+	later, err := PlanBacklog(&sched, backlog)
+	if err != nil || later.Token() == token {
+		t.Fatalf("Expected a different plan to have a different token, got %v", err)
+	}
+	p, err = PlanBacklog(&sched, backlog[:1])
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(p.Unplanned) != 1 {
+		t.Fatalf("Expected no room for a 45 minute task, got %v", p.Planned)
+	}
+
+	_, err = PlanBacklog(&sched, []BacklogTask{{Description: "Short", Estimate: Duration(time.Minute)}})
+	if err == nil {
+		t.Fatalf("Expected an error for a task with too short an estimate")
+	}
+}
@@ -0,0 +1,201 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rrule is a simple iCalendar recurrence rule. Only the parts
+// of RFC 5545's RRULE needed for everyday repeating events are
+// supported; parseRRule rejects anything more elaborate.
+type rrule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []time.Weekday
+	ByMonthDay []int
+}
+
+var icalWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// parseRRule parses the value of an RRULE property. Floating
+// UNTIL times are read in the given location.
+func parseRRule(value string, loc *time.Location) (rrule, error) {
+	r := rrule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(val)
+			switch r.Freq {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+			default:
+				return r, InvalidScheduleError{"Unsupported recurrence frequency " + val + "."}
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(val)
+			if err != nil || r.Interval < 1 {
+				return r, InvalidScheduleError{"Invalid recurrence interval " + val + "."}
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(val)
+			if err != nil || r.Count < 1 {
+				return r, InvalidScheduleError{"Invalid recurrence count " + val + "."}
+			}
+		case "UNTIL":
+			r.Until, err = parseICSTimeValue(val, "", loc)
+			if err != nil {
+				return r, err
+			}
+			if len(val) == 8 {
+				// A date includes the whole day
+				r.Until = r.Until.AddDate(0, 0, 1).Add(-time.Second)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(val, ",") {
+				wd, ok := icalWeekdays[strings.ToUpper(d)]
+				if !ok {
+					return r, InvalidScheduleError{"Unsupported recurrence day " + d + "."}
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n < 1 || n > 31 {
+					return r, InvalidScheduleError{"Unsupported recurrence month day " + d + "."}
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "WKST":
+			// Weeks are taken to start on Monday
+		default:
+			return r, InvalidScheduleError{"Unsupported recurrence rule part " + key + "."}
+		}
+	}
+	if r.Freq == "" {
+		return r, InvalidScheduleError{"Recurrence rule has no frequency."}
+	}
+
+	return r, nil
+}
+
+// occurrencesNear returns the start times of the occurrences
+// of an event first starting at dtstart that fall on the given
+// day or either side of it, in the event's time zone. Checking
+// the neighbouring days catches occurrences that only land on
+// the given day once converted to its time zone.
+func (r rrule) occurrencesNear(dtstart, day time.Time) []time.Time {
+	loc := dtstart.Location()
+	local := day.In(loc)
+	var starts []time.Time
+	for offset := -1; offset <= 1; offset++ {
+		d := civilDate(local).AddDate(0, 0, offset)
+		occurrence := time.Date(d.Year(), d.Month(), d.Day(),
+			dtstart.Hour(), dtstart.Minute(), dtstart.Second(), 0, loc)
+		if occurrence.Before(dtstart) || !r.matches(dtstart, d) {
+			continue
+		}
+		if !r.Until.IsZero() && occurrence.After(r.Until) {
+			continue
+		}
+		if r.Count > 0 && r.countThrough(dtstart, d) > r.Count {
+			continue
+		}
+		starts = append(starts, occurrence)
+	}
+	return starts
+}
+
+// matches returns true if the rule has an occurrence on the
+// given date, ignoring COUNT and UNTIL.
+func (r rrule) matches(dtstart, date time.Time) bool {
+	start := civilDate(dtstart)
+	if len(r.ByDay) > 0 && !containsWeekday(r.ByDay, date.Weekday()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 && !containsInt(r.ByMonthDay, date.Day()) {
+		return false
+	}
+
+	switch r.Freq {
+	case "DAILY":
+		days := int(date.Sub(start).Hours() / 24)
+		return days%r.Interval == 0
+	case "WEEKLY":
+		if len(r.ByDay) == 0 && date.Weekday() != start.Weekday() {
+			return false
+		}
+		weeks := int(weekStart(date).Sub(weekStart(start)).Hours() / (24 * 7))
+		return weeks%r.Interval == 0
+	case "MONTHLY":
+		if len(r.ByDay) == 0 && len(r.ByMonthDay) == 0 && date.Day() != start.Day() {
+			return false
+		}
+		months := (date.Year()-start.Year())*12 + int(date.Month()) - int(start.Month())
+		return months%r.Interval == 0
+	case "YEARLY":
+		if date.Month() != start.Month() {
+			return false
+		}
+		if len(r.ByMonthDay) == 0 && date.Day() != start.Day() {
+			return false
+		}
+		return (date.Year()-start.Year())%r.Interval == 0
+	}
+	return false
+}
+
+// countThrough returns the number of occurrences from
+// dtstart up to and including the given date.
+func (r rrule) countThrough(dtstart, date time.Time) int {
+	count := 0
+	for d := civilDate(dtstart); !d.After(date); d = d.AddDate(0, 0, 1) {
+		if r.matches(dtstart, d) {
+			count++
+		}
+	}
+	return count
+}
+
+// civilDate returns midnight UTC on t's calendar date, so that
+// whole days can be counted without daylight saving changes
+// getting in the way.
+func civilDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// weekStart returns the Monday of the week containing the civil date d.
+func weekStart(d time.Time) time.Time {
+	return d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(nums []int, n int) bool {
+	for _, m := range nums {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
@@ -1,12 +1,8 @@
 package internal
 
 import (
-	"encoding/csv"
-	"errors"
 	"fmt"
-	"io"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -14,7 +10,49 @@ import (
 type Schedule struct {
 	Tasks       TaskList
 	CurrentTask *Task
-	CurrentID   int // ID of the current task
+	CurrentID   int           // ID of the current task
+	Day         time.Time     // Midnight at the start of the day the schedule is for
+	DayStart    time.Duration // Time after midnight at which Day begins; see StartOfDay
+
+	Journal Journal // Changes made to Tasks since the schedule was built
+	Clock   Clock   // Source of the current time; the system clock if nil
+}
+
+// now returns the current time according to the schedule's clock.
+func (s *Schedule) now() time.Time {
+	if s.Clock == nil {
+		return time.Now()
+	}
+	return s.Clock.Now()
+}
+
+// day returns the day the schedule is for, which
+// is the current day if the schedule's Day isn't set.
+func (s *Schedule) day() time.Time {
+	if s.Day.IsZero() {
+		return DayOf(s.now(), s.DayStart)
+	}
+	return s.Day
+}
+
+// Bounds returns the times at which the schedule's day
+// begins and ends. Tasks may run past midnight if the
+// day begins after midnight.
+func (s *Schedule) Bounds() (time.Time, time.Time) {
+	day := s.day()
+	return StartOfDay(day, s.DayStart), StartOfDay(day.AddDate(0, 0, 1), s.DayStart)
+}
+
+// onDay returns true if the task starts and ends
+// within the schedule's day.
+func (s *Schedule) onDay(t Task) bool {
+	start, end := s.Bounds()
+	return !t.StartTime.Before(start) && !t.EndTime.After(end)
+}
+
+// isOver returns true if the schedule's day has ended.
+func (s *Schedule) isOver() bool {
+	return s.day().Before(DayOf(s.now(), s.DayStart))
 }
 
 // GertTasksWithin returns all tasks that occur within a given time frame
@@ -39,23 +77,29 @@ func (s *Schedule) GetTasksWithin(before time.Time, after time.Time) []*Task {
 // to have the given description, tag, and end time. It is not
 // assumed that there will not be a conflict.
 func (s *Schedule) ChangeCurrentTaskUntil(desc, tag string, end time.Time) error {
-	if end.Compare(time.Now()) <= 0 {
+	return s.record("change_current", func() error {
+		return s.changeCurrentTaskUntil(desc, tag, end)
+	})
+}
+
+func (s *Schedule) changeCurrentTaskUntil(desc, tag string, end time.Time) error {
+	now := s.now()
+	if end.Compare(now) <= 0 {
 		return InvalidTimeError{"Task ends before the current time."}
 	}
-	now := time.Now()
-	if end.Day() != now.Day() || end.Month() != now.Month() || end.Year() != now.Year() {
+	if _, dayEnd := s.Bounds(); s.isOver() || end.After(dayEnd) {
 		return InvalidTimeError{"Task must end during the current day."}
 	}
 
-	newCurrent := NewTask(desc, time.Now(), end).WithTag(tag)
+	newCurrent := NewTask(desc, now, end).WithTag(tag)
 	err := newCurrent.Quantize()
 	if err != nil {
 		return err
 	}
 
-	if len(s.Tasks) == 0 || s.Tasks.get(len(s.Tasks)-1).EndTime.Before(time.Now()) {
+	if len(s.Tasks) == 0 || !s.Tasks.get(len(s.Tasks)-1).EndTime.After(now) {
 		s.Tasks = append(s.Tasks, &newCurrent)
-	} else if s.Tasks[0].StartTime.After(time.Now()) {
+	} else if s.Tasks[0].StartTime.After(now) {
 		if !s.Tasks.IsConflict(newCurrent) {
 			s.Tasks = append([]*Task{&newCurrent}, s.Tasks...)
 		} else {
@@ -67,8 +111,8 @@ func (s *Schedule) ChangeCurrentTaskUntil(desc, tag string, end time.Time) error
 		}
 
 	} else if !s.Tasks.IsConflict(newCurrent) {
-		_, idx := s.Tasks.GetTaskAtTime(time.Now())
-		s.Tasks[idx].EndTime = time.Now() // Should be the break
+		_, idx := s.Tasks.GetTaskAtTime(now)
+		s.Tasks[idx].EndTime = now // Should be the break
 		err := s.Tasks[idx].Quantize()
 		if err != nil {
 			return err
@@ -106,7 +150,10 @@ func (s *Schedule) AddTask(t Task) error {
 	}
 	//_, idx := s.Tasks.GetTaskAtTime(t.StartTime)
 	//newTasks, err := s.Tasks.ResolveConflicts(idx, t)
-	err := s.UpdateTimeBlock(t)
+	err := s.record("add", func() error {
+		_, err := s.updateTimeBlock(Split{}, t)
+		return err
+	})
 	if err != nil {
 		return fmt.Errorf("AddTask: %w", err)
 	}
@@ -115,22 +162,96 @@ func (s *Schedule) AddTask(t Task) error {
 	return nil
 }
 
+// Replace replaces all of the schedule's tasks with the given
+// tasks. It returns a TaskListError if the tasks can't make up
+// a TaskList or aren't on the schedule's day. The replacement
+// is journaled, so it can be undone.
+func (s *Schedule) Replace(tasks ...Task) error {
+	var taskErrs []TaskError
+	for i, t := range tasks {
+		if !s.onDay(t) {
+			start, end := s.Bounds()
+			msg := fmt.Sprintf("Task must be within the schedule's day, from %s to %s.",
+				start.Format(time.DateTime), end.Format(time.DateTime))
+			taskErrs = append(taskErrs, TaskError{i, t, InvalidTimeError{msg}})
+		}
+	}
+	if len(taskErrs) > 0 {
+		return fmt.Errorf("Replace: %w", TaskListError{taskErrs})
+	}
+	tl, err := NewTaskList(tasks...)
+	if err != nil {
+		return fmt.Errorf("Replace: %w", err)
+	}
+	return s.record("replace", func() error {
+		s.Tasks = tl
+		return nil
+	})
+}
+
+// Clone returns a copy of the schedule that shares no tasks
+// with it, so that changes can be tried out on the copy.
+func (s *Schedule) Clone() *Schedule {
+	values := s.Tasks.values()
+	c := &Schedule{
+		Tasks:     make(TaskList, len(values)),
+		CurrentID: s.CurrentID,
+		Day:       s.Day,
+		DayStart:  s.DayStart,
+		Journal:   s.Journal.clone(),
+		Clock:     s.Clock,
+	}
+	for i := range values {
+		c.Tasks[i] = &values[i]
+	}
+	if s.CurrentTask != nil && s.CurrentID >= 0 && s.CurrentID < len(c.Tasks) {
+		c.CurrentTask = c.Tasks[s.CurrentID]
+	}
+	return c
+}
+
 // UpdateTimeBlock updates the schedule's task list with
 // the given collection of tasks. It is not assumed that
 // no conflicts will exist, and will alter the existing
 // tasks as needed. It returns an error if the update
 // could not be completed.
 func (s *Schedule) UpdateTimeBlock(tasks ...Task) error {
+	_, err := s.UpdateTimeBlockWith(Split{}, tasks...)
+	return err
+}
+
+// UpdateTimeBlockWith is like UpdateTimeBlock, but makes room for
+// the given tasks using the given strategy. It returns the tasks
+// that were pushed out of the schedule's day, which are removed
+// from the schedule.
+func (s *Schedule) UpdateTimeBlockWith(cs ConflictStrategy, tasks ...Task) ([]Task, error) {
+	var overflow []Task
+	err := s.record("update", func() error {
+		var err error
+		overflow, err = s.updateTimeBlock(cs, tasks...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return overflow, nil
+}
+
+func (s *Schedule) updateTimeBlock(cs ConflictStrategy, tasks ...Task) ([]Task, error) {
+	var overflow []Task
 	for _, t := range tasks {
 		if !t.IsValid() {
-			return InvalidTimeError{"One or more tasks has an invalid time."}
+			return nil, InvalidTimeError{"One or more tasks has an invalid time."}
+		}
+		if s.isOver() {
+			return nil, InvalidTimeError{"The schedule's day is over."}
 		}
-		todayY, todayM, todayD := time.Now().Date()
-		if y, m, d := t.StartTime.Date(); y != todayY || m != todayM || d != todayD {
-			return InvalidTimeError{"Task must start during the current day."}
+		dayStart, dayEnd := s.Bounds()
+		if t.StartTime.Before(dayStart) || !t.StartTime.Before(dayEnd) {
+			return nil, InvalidTimeError{"Task must start during the schedule's day."}
 		}
-		if y, m, d := t.EndTime.Date(); y != todayY || m != todayM || d != todayD {
-			return InvalidTimeError{"Task must end during the current day."}
+		if t.EndTime.After(dayEnd) {
+			return nil, InvalidTimeError{"Task must end during the schedule's day."}
 		}
 
 		/*
@@ -161,23 +282,26 @@ func (s *Schedule) UpdateTimeBlock(tasks ...Task) error {
 
 		//newTasks.sort()
 
-		newTasks, err := s.Tasks.ResolveConflicts(t)
+		newTasks, pushed, err := s.Tasks.ResolveConflictsWith(t, cs, dayEnd)
 		if err != nil {
-			return fmt.Errorf("UpdateTimeBlock: %w", err)
+			return nil, fmt.Errorf("UpdateTimeBlock: %w", err)
 		}
 		s.Tasks = newTasks
 		s.FixBreaks()
+		overflow = append(overflow, pushed...)
 	}
 
-	return nil
+	return overflow, nil
 }
 
 // TODO add godoc comment
 func (s *Schedule) FixBreaks() {
 	for i := 0; i < len(s.Tasks)-1; i++ {
 		if s.Tasks[i].IsBreak() && s.Tasks[i+1].IsBreak() {
+			s.Tasks[i+1].ID = s.Tasks[i].ID
 			s.Tasks[i+1].StartTime = s.Tasks[i].StartTime
 			s.Tasks = append(s.Tasks[:i], s.Tasks[i+1:]...)
+			i-- // The merged break may be followed by another
 		} else if !s.Tasks[i].EndTime.Equal(s.Tasks[i+1].StartTime) {
 			b := Break(s.Tasks[i].EndTime, s.Tasks[i+1].StartTime)
 			s.Tasks = append(s.Tasks[:i+1], append([]*Task{&b}, s.Tasks[i+1:]...)...)
@@ -185,6 +309,94 @@ func (s *Schedule) FixBreaks() {
 	}
 }
 
+// TaskPatch holds changes to be made to a single task.
+// Fields that are nil are left unchanged.
+type TaskPatch struct {
+	Description *string    `json:"Description"`
+	Tag         *string    `json:"Tag"`
+	StartTime   *time.Time `json:"Start"`
+	EndTime     *time.Time `json:"End"`
+	Fixed       *bool      `json:"Fixed"`
+}
+
+// PatchTask applies the given changes to the task with the
+// given ID. If the task's times change, it is moved and any
+// conflicts are resolved as they are in UpdateTimeBlock.
+// The task keeps its ID.
+func (s *Schedule) PatchTask(id string, p TaskPatch) error {
+	return s.record("patch", func() error {
+		task, _ := s.Tasks.GetTaskByID(id)
+		if task == nil {
+			return TaskNotFoundError{"No task with ID " + id + "."}
+		}
+
+		updated := *task
+		if p.Description != nil {
+			updated.Description = *p.Description
+		}
+		if p.Tag != nil {
+			updated.Tag = *p.Tag
+		}
+		if p.Fixed != nil {
+			updated.Fixed = *p.Fixed
+		}
+		if p.StartTime == nil && p.EndTime == nil {
+			*task = updated
+			return nil
+		}
+
+		if p.StartTime != nil {
+			updated.StartTime = *p.StartTime
+		}
+		if p.EndTime != nil {
+			updated.EndTime = *p.EndTime
+		}
+		err := updated.Quantize()
+		if err != nil {
+			return err
+		}
+		*task = Break(task.StartTime, task.EndTime)
+		_, err = s.updateTimeBlock(Split{}, updated)
+		if err != nil {
+			return err
+		}
+		s.trimBreaks()
+
+		return nil
+	})
+}
+
+// RemoveTask removes the task with the given ID from the
+// schedule, leaving a break in its place.
+func (s *Schedule) RemoveTask(id string) error {
+	return s.record("remove", func() error {
+		task, _ := s.Tasks.GetTaskByID(id)
+		if task == nil {
+			return TaskNotFoundError{"No task with ID " + id + "."}
+		}
+		if task.IsBreak() {
+			return InvalidScheduleError{"Breaks cannot be removed."}
+		}
+
+		*task = Break(task.StartTime, task.EndTime)
+		s.FixBreaks()
+		s.trimBreaks()
+
+		return nil
+	})
+}
+
+// trimBreaks removes any breaks from the start
+// and end of the schedule's task list.
+func (s *Schedule) trimBreaks() {
+	for len(s.Tasks) > 0 && s.Tasks[0].IsBreak() {
+		s.Tasks = s.Tasks[1:]
+	}
+	for len(s.Tasks) > 0 && s.Tasks[len(s.Tasks)-1].IsBreak() {
+		s.Tasks = s.Tasks[:len(s.Tasks)-1]
+	}
+}
+
 // UpdateCurrentTask checks the schedule's task list for the
 // task scheduled for the time that the function is called,
 // and updates the schedule's CurrentTask member accordingly.
@@ -192,7 +404,7 @@ func (s *Schedule) FixBreaks() {
 // scheduled task.
 func (s *Schedule) UpdateCurrentTask() error {
 	// For use with timer or change/request from client
-	s.CurrentTask, s.CurrentID = s.Tasks.GetTaskAtTime(time.Now())
+	s.CurrentTask, s.CurrentID = s.Tasks.GetTaskAtTime(s.now())
 	if s.CurrentID == -1 {
 		return InvalidScheduleError{}
 	}
@@ -219,95 +431,78 @@ func (s Schedule) String() string {
 	return sb.String()
 }
 
+// ScheduleOptions decide what day a new schedule is for, and
+// where it reads the current time from. The zero value gives
+// a schedule for the current day on the system clock, with
+// days that begin at midnight.
+type ScheduleOptions struct {
+	Clock    Clock         // Source of the current time; the system clock if nil
+	DayStart time.Duration // Time after midnight at which each day begins
+	Day      time.Time     // The day the schedule is for; the current day if zero
+}
+
+// clock returns the options' clock, or the system clock if none is set.
+func (o ScheduleOptions) clock() Clock {
+	if o.Clock == nil {
+		return SystemClock{}
+	}
+	return o.Clock
+}
+
+// day returns the day the options give, or the current
+// day according to the options' clock if none is set.
+func (o ScheduleOptions) day() time.Time {
+	if o.Day.IsZero() {
+		return DayOf(o.clock().Now(), o.DayStart)
+	}
+	return o.Day
+}
+
 // NewSchedule returns a new Schedule with the given TaskList as a Tasks
 // member. It assumes the TaskList is consistent, and returns an empty
 // schedule otherwise.
-func NewSchedule(taskList TaskList) Schedule {
+func NewSchedule(taskList TaskList, opts ScheduleOptions) Schedule {
 	if !taskList.IsConsistent() {
 		return Schedule{}
 	}
-	currentTask, currentIdx := taskList.GetTaskAtTime(time.Now())
+	c := opts.clock()
+	currentTask, currentIdx := taskList.GetTaskAtTime(c.Now())
 	return Schedule{
 		Tasks:       taskList,
 		CurrentTask: currentTask,
 		CurrentID:   currentIdx,
+		Day:         opts.day(),
+		DayStart:    opts.DayStart,
+		Clock:       c,
 	}
 }
 
-// BuildFromFile creates a schedule from a csv file with the given name
-func BuildFromFile(fileName string) (*Schedule, error) {
+// BuildFromFile creates a schedule from the file with the given
+// name. The file's extension decides its format; see
+// FormatFromFileName and ParseTasks.
+func BuildFromFile(fileName string, opts ScheduleOptions) (*Schedule, error) {
 	// TODO log?
 	f, err := os.Open(fileName)
 	if err != nil {
 		return nil, fmt.Errorf("BuildFromFile: %w", err)
 	}
-	r := csv.NewReader(f)
-	taskList := []Task{}
-	lc := 0
-	var desc string
-	var start time.Time
-	var end time.Time
-	var tag string
-
-	line, err := r.Read()
-	for err != io.EOF {
-		if err != nil {
-			return nil, fmt.Errorf("BuildFromFile: %w", err)
-		}
-		lc++
-		if len(line) < 3 {
-			return nil,
-				errors.New("BuildFromFile: Field missing from line " + strconv.Itoa(lc))
-		}
-		desc = line[0]
-
-		// Set task times to the current day (for now)
-		now := time.Now()
-		starttime := line[1]
-		if len(strings.Split(starttime, ":")) == 2 {
-			starttime += ":00"
-		}
-		start, err = time.Parse(time.TimeOnly, starttime)
-		if err != nil {
-			return nil, errors.New("BuildFromFile: time value improperly formatted on line " + strconv.Itoa(lc))
-		}
-		start = time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, time.Local)
-		end, err = time.Parse(time.TimeOnly, line[2])
-		if err != nil {
-			return nil, errors.New("BuildFromFile: time value improperly formatted on line " + strconv.Itoa(lc))
-		}
-
-		end = time.Date(now.Year(), now.Month(), now.Day(), end.Hour(), end.Minute(), 0, 0, time.Local)
-		tag = strings.TrimSpace(line[3])
-		var task Task
-		if len(tag) > 0 {
-			task = NewTask(desc, start, end).WithTag(tag)
-		} else {
-			task = NewTask(desc, start, end)
-		}
-		if task.IsEmpty() {
-			return nil, errors.New("BuildFromFile: Task could not be created on line " + strconv.Itoa(lc))
-		}
+	defer f.Close()
 
-		taskList = append(taskList, task)
-		line, err = r.Read()
-		if err != nil && err != io.EOF {
-			return nil, fmt.Errorf("BuildFromFile: %w", err)
-		}
-	}
-	if err != nil && err != io.EOF {
+	sched, err := BuildFromReader(f, FormatFromFileName(fileName), opts)
+	if err != nil {
 		return nil, fmt.Errorf("BuildFromFile: %w", err)
 	}
+	return sched, nil
+}
 
-	tList, err := NewTaskList(taskList...)
+// scheduleFromTasks returns a new schedule made up of
+// the given tasks, for the day the options give.
+func scheduleFromTasks(tasks []Task, opts ScheduleOptions) (*Schedule, error) {
+	tList, err := NewTaskList(tasks...)
 	if err != nil {
-		return nil, fmt.Errorf("BuildFromFile: %w", err)
+		return nil, fmt.Errorf("scheduleFromTasks: %w", err)
 	}
 
-	current, index := tList.GetTaskAtTime(time.Now())
-	return &Schedule{
-		Tasks:       tList,
-		CurrentTask: current,
-		CurrentID:   index,
-	}, nil
+	s := NewSchedule(tList, opts)
+	return &s, nil
 }
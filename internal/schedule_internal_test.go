@@ -1,6 +1,10 @@
 package internal
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -47,7 +51,7 @@ func TestGetCurrentTask(t *testing.T) {
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
-	s2 := NewSchedule(tl2)
+	s2 := NewSchedule(tl2, ScheduleOptions{})
 	expectedBreak := Break(taskBefore.EndTime, taskAfter.StartTime)
 	if s2.GetCurrentTaskStr() != expectedBreak.String() {
 		t.Fatalf("Expected: %s, Got: %s", expectedBreak.String(), s2.GetCurrentTaskStr())
@@ -56,7 +60,7 @@ func TestGetCurrentTask(t *testing.T) {
 }
 
 func TestBuildFromFile(t *testing.T) {
-	mealsWithBreaks, err := BuildFromFile("./test_data/meals_w_breaks.csv")
+	mealsWithBreaks, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -74,12 +78,12 @@ func TestBuildFromFile(t *testing.T) {
 		t.Fatalf("Expected: %s\n Got: %s", expected, mealsWithBreaks.String())
 	}
 
-	_, err = BuildFromFile("./test_data/overlap1.csv")
+	_, err = BuildFromFile("./test_data/overlap1.csv", ScheduleOptions{})
 	if err == nil {
 		t.Fatalf("File with overlapping tasks should not compile")
 	}
 
-	quantizedOne, err := BuildFromFile("./test_data/quantize_test1.csv")
+	quantizedOne, err := BuildFromFile("./test_data/quantize_test1.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -98,7 +102,7 @@ func TestBuildFromFile(t *testing.T) {
 		t.Fatalf("Expected: %s\n Got: %s", expected, quantizedOne.String())
 	}
 
-	quantizedTwo, err := BuildFromFile("./test_data/quantize_test2.csv")
+	quantizedTwo, err := BuildFromFile("./test_data/quantize_test2.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -119,7 +123,7 @@ func TestBuildFromFile(t *testing.T) {
 
 func TestUpdateCurrentTask(t *testing.T) {
 	// TODO Add more tests?
-	validSchedule := NewSchedule(svalidList)
+	validSchedule := NewSchedule(svalidList, ScheduleOptions{})
 	validSchedule.CurrentID = 0
 	validSchedule.CurrentTask = validSchedule.Tasks[0]
 	currentTask := *validSchedule.CurrentTask
@@ -143,7 +147,7 @@ func TestUpdateCurrentTask(t *testing.T) {
 }
 
 func TestGetTasksWithin(t *testing.T) {
-	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv")
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -197,7 +201,7 @@ func TestGetTasksWithin(t *testing.T) {
 }
 
 func TestUpdateTimeBlock(t *testing.T) {
-	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv")
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -248,7 +252,7 @@ func TestUpdateTimeBlock(t *testing.T) {
 }
 
 func TestAddTask(t *testing.T) {
-	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv")
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -299,7 +303,7 @@ func TestAddTask(t *testing.T) {
 }
 
 func TestChangeCurrentTaskUntil(t *testing.T) {
-	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv")
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
@@ -324,7 +328,7 @@ func TestFixBreaks(t *testing.T) {
 	if err != nil {
 		t.Fatalf("NewTaskList should not throw error: %s", err.Error())
 	}
-	sched := NewSchedule(tl)
+	sched := NewSchedule(tl, ScheduleOptions{})
 	l := len(sched.Tasks)
 	sched.FixBreaks()
 	if l != len(sched.Tasks) {
@@ -335,3 +339,183 @@ func TestFixBreaks(t *testing.T) {
 func TestNewSchedule(t *testing.T) {
 	// TODO implement
 }
+
+func TestTaskIDs(t *testing.T) {
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	ids := make(map[string]bool)
+	for _, task := range sched.Tasks {
+		if task.ID == "" {
+			t.Fatalf("Task has no ID: %s", task.String())
+		}
+		if ids[task.ID] {
+			t.Fatalf("Duplicate task ID: %s", task.ID)
+		}
+		ids[task.ID] = true
+	}
+
+	// Splitting a break keeps its ID on the first half
+	breakID := sched.Tasks[1].ID
+	nTask := NewTask("Nap", sched.Tasks[1].StartTime.Add(1*time.Hour), sched.Tasks[1].EndTime.Add(-1*time.Hour))
+	err = sched.UpdateTimeBlock(nTask)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.Tasks[1].ID != breakID {
+		t.Fatalf("Expected split task to keep ID %s, got %s", breakID, sched.Tasks[1].ID)
+	}
+	if sched.Tasks[2].ID != nTask.ID {
+		t.Fatalf("Expected new task to keep ID %s, got %s", nTask.ID, sched.Tasks[2].ID)
+	}
+	if sched.Tasks[3].ID == breakID {
+		t.Fatalf("Second half of a split task should get a new ID")
+	}
+
+	// Removing the task merges the surrounding breaks under the first one's ID
+	err = sched.RemoveTask(nTask.ID)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(sched.Tasks) != 7 {
+		t.Fatalf("Expected 7 tasks, got %d\n%s", len(sched.Tasks), sched.String())
+	}
+	if sched.Tasks[1].ID != breakID {
+		t.Fatalf("Expected merged break to keep ID %s, got %s", breakID, sched.Tasks[1].ID)
+	}
+}
+
+func TestPatchTask(t *testing.T) {
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	lunch := *sched.Tasks[2]
+
+	desc, tag := "Eat Brunch", "meal"
+	err = sched.PatchTask(lunch.ID, TaskPatch{Description: &desc, Tag: &tag})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	task, idx := sched.Tasks.GetTaskByID(lunch.ID)
+	if idx != 2 || task.Description != desc || task.Tag != tag {
+		t.Fatalf("Expected renamed task at index 2, got %v at %d", task, idx)
+	}
+
+	start := lunch.StartTime.Add(-2 * time.Hour)
+	end := lunch.EndTime.Add(-2 * time.Hour)
+	err = sched.PatchTask(lunch.ID, TaskPatch{StartTime: &start, EndTime: &end})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	expected := `
+	[09:00:00-09:15:00] Eat Breakfast (food)
+	[09:15:00-10:15:00] Break (break)
+	[10:15:00-10:45:00] Eat Brunch (meal)
+	[10:45:00-17:00:00] Break (break)
+	[17:00:00-18:00:00] Eat Dinner (food)
+	[18:00:00-23:30:00] Break (break)
+	[23:30:00-23:45:00] Go To Sleep ()`
+	got := strings.Replace(strings.Join(strings.Fields(sched.String()), ""),
+		"->", "", -1)
+	if strings.Join(strings.Fields(expected), "") != got {
+		t.Fatalf("Expected: %s\n Got: %s", expected, sched.String())
+	}
+	if task, _ := sched.Tasks.GetTaskByID(lunch.ID); task == nil {
+		t.Fatalf("Moved task should keep its ID")
+	}
+
+	err = sched.PatchTask("missing", TaskPatch{Description: &desc})
+	if !errors.As(err, &TaskNotFoundError{}) {
+		t.Fatalf("Expected TaskNotFoundError, got: %v", err)
+	}
+	err = sched.RemoveTask(sched.Tasks[1].ID)
+	if err == nil {
+		t.Fatalf("Expected error when removing a break")
+	}
+}
+
+func TestBuildFromReader(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 12, 30, 0, 0, time.Local))
+	f, err := os.Open("./test_data/meals_w_breaks.csv")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer f.Close()
+	sched, err := BuildFromReader(f, FormatFromFileName("meals.CSV"), ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if sched.CurrentTask == nil || sched.CurrentTask.Description != "Eat Lunch" {
+		t.Fatalf("Expected current task to be \"Eat Lunch\", got: %v", sched.CurrentTask)
+	}
+
+	var buf bytes.Buffer
+	err = json.NewEncoder(&buf).Encode(sched.Tasks)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	fromJSON, err := BuildFromReader(&buf, FormatJSON, ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if fromJSON.String() != sched.String() {
+		t.Fatalf("Expected: %s\n Got: %s", sched.String(), fromJSON.String())
+	}
+
+	_, err = BuildFromReader(strings.NewReader(""), Format("xlsx"), ScheduleOptions{Clock: c})
+	if !errors.As(err, &InvalidScheduleError{}) {
+		t.Fatalf("Expected InvalidScheduleError for an unknown format, got: %v", err)
+	}
+}
+
+func TestCloneAndReplace(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 12, 30, 0, 0, time.Local))
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	original := sched.String()
+
+	clone := sched.Clone()
+	if clone.CurrentTask != clone.Tasks[clone.CurrentID] {
+		t.Fatalf("Expected clone's current task to be one of its own tasks")
+	}
+	now := c.Now()
+	err = clone.Replace(NewTask("Nap", now.Add(time.Hour), now.Add(2*time.Hour)))
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(clone.Tasks) != 1 || !clone.Journal.CanUndo() {
+		t.Fatalf("Expected replaced clone with a journal entry, got: %s", clone.String())
+	}
+	if sched.String() != original || sched.Journal.CanUndo() {
+		t.Fatalf("Expected original to be unchanged, got: %s", sched.String())
+	}
+
+	err = clone.Undo()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	clone.UpdateCurrentTask()
+	if clone.String() != original {
+		t.Fatalf("Expected: %s\n Got: %s", original, clone.String())
+	}
+
+	err = clone.Replace(NewTask("Nap", now, now.Add(time.Hour)), NewTask("Walk", now, now.Add(time.Hour)))
+	if !errors.As(err, &TaskListError{}) {
+		t.Fatalf("Expected TaskListError, got: %v", err)
+	}
+
+	tomorrow := now.AddDate(0, 0, 1)
+	err = clone.Replace(NewTask("Nap", tomorrow, tomorrow.Add(time.Hour)))
+	if !errors.As(err, &InvalidTimeError{}) {
+		t.Fatalf("Expected InvalidTimeError for a task on another day, got: %v", err)
+	}
+	clone.Day = DayOf(tomorrow, 0)
+	err = clone.Replace(NewTask("Nap", tomorrow, tomorrow.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("Expected tasks on the schedule's day to be accepted, got: %v", err)
+	}
+}
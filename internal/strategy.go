@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConflictStrategy is a way of making room in a TaskList for a
+// new task that overlaps some of its tasks.
+type ConflictStrategy interface {
+	// Resolve returns a copy of tl with newTask added. Tasks that
+	// would have to be moved past end no longer fit, and are left out
+	// of the copy and returned separately. A zero end has no limit.
+	Resolve(tl TaskList, newTask Task, end time.Time) (TaskList, []Task, error)
+}
+
+// ParseConflictStrategy returns the strategy with the given name:
+// split, shift-later, shrink-to-fit, reject or compress. The shorter
+// names shift and shrink are accepted too, and an empty name is the
+// same as shift-later.
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "split":
+		return Split{}, nil
+	case "", "shift", "shift-later":
+		return ShiftLater{}, nil
+	case "shrink", "shrink-to-fit":
+		return ShrinkToFit{}, nil
+	case "reject":
+		return Reject{}, nil
+	case "compress", "compress-proportionally":
+		return Compress{}, nil
+	}
+	return nil, InvalidScheduleError{fmt.Sprintf("Unknown conflict strategy %q. Use split, shift-later, shrink-to-fit, reject or compress.", name)}
+}
+
+// Split cuts short, splits or removes the flexible tasks that the
+// new task overlaps; see Resolve. It fails if the new task overlaps
+// a fixed task, since those can't be cut. Nothing is ever pushed
+// past the end.
+type Split struct{}
+
+func (Split) Resolve(tl TaskList, newTask Task, end time.Time) (TaskList, []Task, error) {
+	if err := checkFixed(tl, newTask); err != nil {
+		return nil, nil, err
+	}
+	resolved, err := append(TaskList{}, tl...).ResolveConflicts(newTask)
+	return resolved, nil, err
+}
+
+// ShiftLater moves flexible tasks later to make room for the new
+// task, using up breaks first, and leaves fixed tasks alone. A
+// flexible task that the new task interrupts is split, and the
+// rest of it is done after the new task. Tasks keep their order
+// and length, and are only moved as far as they need to be to
+// avoid the new task, fixed tasks, and the tasks moved before them.
+// The result has no breaks, since the time they took may have been
+// used up by the tasks that were moved.
+type ShiftLater struct{}
+
+func (ShiftLater) Resolve(tl TaskList, newTask Task, end time.Time) (TaskList, []Task, error) {
+	if newTask.ID == "" {
+		newTask.ID = NewTaskID()
+	}
+	fixed, flexible, err := aroundTask(tl, newTask)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockers := append([]Task{newTask}, fixed...)
+	result := TaskList{}
+	for i := range blockers {
+		result = append(result, &blockers[i])
+	}
+	var overflow []Task
+	var earliest time.Time // When the next flexible task can start
+	for _, t := range flexible {
+		length := t.EndTime.Sub(t.StartTime)
+		if t.StartTime.Before(earliest) {
+			t.StartTime = earliest
+		}
+		for moved := true; moved; {
+			moved = false
+			for _, b := range blockers {
+				if b.StartTime.Before(t.StartTime.Add(length)) && t.StartTime.Before(b.EndTime) {
+					t.StartTime = b.EndTime
+					moved = true
+				}
+			}
+		}
+		t.EndTime = t.StartTime.Add(length)
+		if !end.IsZero() && t.EndTime.After(end) {
+			overflow = append(overflow, t)
+			continue
+		}
+		earliest = t.EndTime
+		placed := t
+		result = append(result, &placed)
+	}
+	result.sort()
+
+	return result, overflow, nil
+}
+
+// ShrinkToFit leaves the other tasks alone, and shrinks the new
+// task to the free time it starts in: it starts when any task it
+// overlaps at its start ends, and ends when the next task begins.
+// It fails if that leaves the new task shorter than 5 minutes.
+type ShrinkToFit struct{}
+
+func (ShrinkToFit) Resolve(tl TaskList, newTask Task, end time.Time) (TaskList, []Task, error) {
+	for _, t := range tl {
+		if t.IsBreak() || !t.Conflicts(newTask) {
+			continue
+		}
+		if t.StartTime.Compare(newTask.StartTime) <= 0 {
+			newTask.StartTime = t.EndTime
+		} else {
+			newTask.EndTime = t.StartTime
+		}
+	}
+	if !newTask.IsValid() {
+		return nil, nil, TimeConflictError{"There is no free time of at least 5 minutes for the task."}
+	}
+	return Split{}.Resolve(tl, newTask, end)
+}
+
+// Reject leaves the task list unchanged and fails if the
+// new task overlaps any task other than a break.
+type Reject struct{}
+
+func (Reject) Resolve(tl TaskList, newTask Task, end time.Time) (TaskList, []Task, error) {
+	for _, t := range tl {
+		if !t.IsBreak() && t.Conflicts(newTask) {
+			return nil, nil, TimeConflictError{fmt.Sprintf("Task overlaps task %q.", t.Description)}
+		}
+	}
+	return Split{}.Resolve(tl, newTask, end)
+}
+
+// Compress moves flexible tasks later as ShiftLater does, but if
+// they would run into the next fixed task, or past the end, the
+// tasks between the new task and that limit are packed together
+// and shortened in proportion to their length. Tasks are shortened
+// to no less than 5 minutes, and any that still don't fit are left
+// out.
+type Compress struct{}
+
+func (Compress) Resolve(tl TaskList, newTask Task, end time.Time) (TaskList, []Task, error) {
+	if newTask.ID == "" {
+		newTask.ID = NewTaskID()
+	}
+	limit := end
+	for _, t := range tl {
+		if t.Fixed && !t.StartTime.Before(newTask.EndTime) {
+			limit = t.StartTime
+			break
+		}
+	}
+	if !end.IsZero() && limit.After(end) {
+		limit = end
+	}
+	if limit.IsZero() {
+		return ShiftLater{}.Resolve(tl, newTask, end)
+	}
+	fixed, flexible, err := aroundTask(tl, newTask)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := TaskList{&newTask}
+	for i := range fixed {
+		result = append(result, &fixed[i])
+	}
+	var window []Task // The tasks that may have to be compressed
+	var total time.Duration
+	for i, t := range flexible {
+		if t.StartTime.Before(newTask.StartTime) || !t.StartTime.Before(limit) {
+			result = append(result, &flexible[i])
+			continue
+		}
+		window = append(window, t)
+		total += t.EndTime.Sub(t.StartTime)
+	}
+
+	// Tasks are first moved as they would be by ShiftLater
+	start := newTask.EndTime
+	fits := true
+	for i := range window {
+		length := window[i].EndTime.Sub(window[i].StartTime)
+		if window[i].StartTime.Before(start) {
+			window[i].StartTime = start
+		}
+		window[i].EndTime = window[i].StartTime.Add(length)
+		start = window[i].EndTime
+		fits = fits && !start.After(limit)
+	}
+	if !fits {
+		available := limit.Sub(newTask.EndTime)
+		start = newTask.EndTime
+		for i := range window {
+			length := window[i].EndTime.Sub(window[i].StartTime)
+			length = time.Duration(float64(length) * float64(available) / float64(total)).Truncate(5 * time.Minute)
+			if length < 5*time.Minute {
+				length = 5 * time.Minute
+			}
+			window[i].StartTime = start
+			window[i].EndTime = start.Add(length)
+			start = window[i].EndTime
+		}
+	}
+
+	var overflow []Task
+	for i := range window {
+		if window[i].EndTime.After(limit) {
+			overflow = append(overflow, window[i])
+			continue
+		}
+		result = append(result, &window[i])
+	}
+	result.sort()
+
+	return result, overflow, nil
+}
+
+// aroundTask returns the fixed and flexible tasks in tl, leaving out
+// breaks. A flexible task that newTask interrupts is split in two at
+// newTask's start, and the second part is given a new ID. It returns
+// a TimeConflictError if newTask overlaps a fixed task.
+func aroundTask(tl TaskList, newTask Task) ([]Task, []Task, error) {
+	if err := checkFixed(tl, newTask); err != nil {
+		return nil, nil, err
+	}
+	var fixed, flexible []Task
+	for _, t := range tl {
+		switch {
+		case t.IsBreak():
+		case t.Fixed:
+			fixed = append(fixed, *t)
+		case t.StartTime.Before(newTask.StartTime) && t.EndTime.After(newTask.StartTime):
+			rest := *t
+			rest.ID = NewTaskID()
+			rest.StartTime = newTask.StartTime
+			interrupted := *t
+			interrupted.EndTime = newTask.StartTime
+			flexible = append(flexible, interrupted, rest)
+		default:
+			flexible = append(flexible, *t)
+		}
+	}
+	return fixed, flexible, nil
+}
+
+// checkFixed returns a TimeConflictError if the new
+// task overlaps a fixed task in tl.
+func checkFixed(tl TaskList, newTask Task) error {
+	for _, t := range tl {
+		if t.Fixed && t.Conflicts(newTask) {
+			return TimeConflictError{fmt.Sprintf("Task overlaps fixed task %q.", t.Description)}
+		}
+	}
+	return nil
+}
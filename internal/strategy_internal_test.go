@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+var strategyDay = time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+
+// at returns the given time of day on strategyDay.
+func at(hour, min int) time.Time {
+	return strategyDay.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+}
+
+// strategyTasks returns a day of flexible tasks around a fixed meeting:
+// A 09:00-10:00, B 10:00-10:30, Meeting 11:00-12:00, C 12:00-13:00 and
+// D 22:30-23:30, with breaks between them.
+func strategyTasks(t *testing.T) TaskList {
+	tl, err := NewTaskList(
+		NewTask("A", at(9, 0), at(10, 0)),
+		NewTask("B", at(10, 0), at(10, 30)),
+		NewTask("Meeting", at(11, 0), at(12, 0)).AsFixed(true),
+		NewTask("C", at(12, 0), at(13, 0)),
+		NewTask("D", at(22, 30), at(23, 30)),
+	)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	return tl
+}
+
+// summary describes each task in the list and its times.
+func summary(tl TaskList) string {
+	s := ""
+	for _, task := range tl {
+		s += fmt.Sprintf("%s %s-%s, ", task.Description, task.StartTime.Format("15:04"), task.EndTime.Format("15:04"))
+	}
+	return s
+}
+
+type strategyTest struct {
+	task     Task
+	expected string
+	overflow int
+}
+
+// testStrategy checks the result of resolving each test's task
+// into the strategy tasks, and that the original list is unchanged.
+func testStrategy(t *testing.T, cs ConflictStrategy, tests []strategyTest) {
+	tl := strategyTasks(t)
+	before := summary(tl)
+	for _, test := range tests {
+		resolved, overflow, err := tl.ResolveConflictsWith(test.task, cs, strategyDay.AddDate(0, 0, 1))
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		if got := summary(resolved); got != test.expected {
+			t.Fatalf("Expected %s\ngot %s", test.expected, got)
+		}
+		if len(overflow) != test.overflow {
+			t.Fatalf("Expected %d tasks not to fit, got %v", test.overflow, overflow)
+		}
+		if !resolved.IsConsistent() {
+			t.Fatalf("Expected no overlapping tasks, got %s", summary(resolved))
+		}
+	}
+	if summary(tl) != before {
+		t.Fatalf("Expected the task list to be unchanged, got %s", summary(tl))
+	}
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	tests := map[string]ConflictStrategy{
+		"":              ShiftLater{},
+		"split":         Split{},
+		"Shift-Later":   ShiftLater{},
+		"shift":         ShiftLater{},
+		"shrink-to-fit": ShrinkToFit{},
+		"reject":        Reject{},
+		"compress":      Compress{},
+	}
+	for name, expected := range tests {
+		cs, err := ParseConflictStrategy(name)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		if cs != expected {
+			t.Fatalf("Expected %T for %q, got %T", expected, name, cs)
+		}
+	}
+	_, err := ParseConflictStrategy("squash")
+	if !errors.As(err, &InvalidScheduleError{}) {
+		t.Fatalf("Expected InvalidScheduleError for an unknown strategy, got: %v", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	testStrategy(t, Split{}, []strategyTest{
+		{NewTask("Call", at(9, 30), at(10, 15)),
+			"A 09:00-09:30, Call 09:30-10:15, B 10:15-10:30, Break 10:30-11:00, Meeting 11:00-12:00, C 12:00-13:00, Break 13:00-22:30, D 22:30-23:30, ", 0},
+	})
+
+	_, _, err := Split{}.Resolve(strategyTasks(t), NewTask("Call", at(11, 30), at(12, 30)), time.Time{})
+	if !errors.As(err, &TimeConflictError{}) {
+		t.Fatalf("Expected TimeConflictError for a task overlapping a fixed task, got: %v", err)
+	}
+}
+
+func TestShiftLater(t *testing.T) {
+	testStrategy(t, ShiftLater{}, []strategyTest{
+		// An interrupted task is split, and breaks are used up before fixed tasks are reached
+		{NewTask("Call", at(9, 30), at(10, 0)),
+			"A 09:00-09:30, Call 09:30-10:00, A 10:00-10:30, B 10:30-11:00, Meeting 11:00-12:00, C 12:00-13:00, D 22:30-23:30, ", 0},
+		// Tasks are moved past fixed tasks
+		{NewTask("Call", at(10, 0), at(11, 0)),
+			"A 09:00-10:00, Call 10:00-11:00, Meeting 11:00-12:00, B 12:00-12:30, C 12:30-13:30, D 22:30-23:30, ", 0},
+		// Tasks pushed past the end of the day don't fit
+		{NewTask("Late", at(22, 0), at(23, 30)),
+			"A 09:00-10:00, B 10:00-10:30, Meeting 11:00-12:00, C 12:00-13:00, Late 22:00-23:30, ", 1},
+	})
+
+	_, _, err := strategyTasks(t).ResolveConflictsWith(NewTask("Call", at(11, 30), at(12, 30)), ShiftLater{}, time.Time{})
+	if !errors.As(err, &TimeConflictError{}) {
+		t.Fatalf("Expected TimeConflictError for a task overlapping a fixed task, got: %v", err)
+	}
+
+	// In a schedule, the gaps left by moved tasks become breaks
+	sched := NewSchedule(strategyTasks(t), ScheduleOptions{Clock: NewFakeClock(at(8, 0))})
+	overflow, err := sched.UpdateTimeBlockWith(ShiftLater{}, NewTask("Call", at(10, 0), at(11, 0)))
+	if err != nil || len(overflow) != 0 {
+		t.Fatalf("Expected the update to succeed, got %v, %v", err, overflow)
+	}
+	expected := "A 09:00-10:00, Call 10:00-11:00, Meeting 11:00-12:00, B 12:00-12:30, C 12:30-13:30, Break 13:30-22:30, D 22:30-23:30, "
+	if got := summary(sched.Tasks); got != expected {
+		t.Fatalf("Expected %s\ngot %s", expected, got)
+	}
+}
+
+func TestShrinkToFit(t *testing.T) {
+	testStrategy(t, ShrinkToFit{}, []strategyTest{
+		{NewTask("Call", at(10, 15), at(11, 30)),
+			"A 09:00-10:00, B 10:00-10:30, Call 10:30-11:00, Meeting 11:00-12:00, C 12:00-13:00, Break 13:00-22:30, D 22:30-23:30, ", 0},
+		{NewTask("Call", at(13, 0), at(14, 0)),
+			"A 09:00-10:00, B 10:00-10:30, Break 10:30-11:00, Meeting 11:00-12:00, C 12:00-13:00, Call 13:00-14:00, Break 14:00-22:30, D 22:30-23:30, ", 0},
+	})
+
+	_, _, err := ShrinkToFit{}.Resolve(strategyTasks(t), NewTask("Call", at(11, 10), at(11, 50)), time.Time{})
+	if !errors.As(err, &TimeConflictError{}) {
+		t.Fatalf("Expected TimeConflictError when there's no free time, got: %v", err)
+	}
+}
+
+func TestReject(t *testing.T) {
+	testStrategy(t, Reject{}, []strategyTest{
+		{NewTask("Call", at(10, 30), at(11, 0)),
+			"A 09:00-10:00, B 10:00-10:30, Call 10:30-11:00, Meeting 11:00-12:00, C 12:00-13:00, Break 13:00-22:30, D 22:30-23:30, ", 0},
+	})
+
+	_, _, err := Reject{}.Resolve(strategyTasks(t), NewTask("Call", at(10, 15), at(10, 45)), time.Time{})
+	if !errors.As(err, &TimeConflictError{}) {
+		t.Fatalf("Expected TimeConflictError for a task overlapping another, got: %v", err)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	testStrategy(t, Compress{}, []strategyTest{
+		// Tasks that can be moved without running into a fixed task aren't compressed
+		{NewTask("Call", at(10, 0), at(10, 30)),
+			"A 09:00-10:00, Call 10:00-10:30, B 10:30-11:00, Meeting 11:00-12:00, C 12:00-13:00, D 22:30-23:30, ", 0},
+		// A and B share the half hour before the meeting in proportion
+		{NewTask("Call", at(9, 0), at(10, 30)),
+			"Call 09:00-10:30, A 10:30-10:50, B 10:50-11:00, Meeting 11:00-12:00, C 12:00-13:00, D 22:30-23:30, ", 0},
+		// Tasks are no shorter than 5 minutes, so B doesn't fit
+		{NewTask("Call", at(9, 0), at(10, 55)),
+			"Call 09:00-10:55, A 10:55-11:00, Meeting 11:00-12:00, C 12:00-13:00, D 22:30-23:30, ", 1},
+		// After the last fixed task, tasks are compressed to fit the day
+		{NewTask("Late", at(22, 0), at(23, 30)),
+			"A 09:00-10:00, B 10:00-10:30, Meeting 11:00-12:00, C 12:00-13:00, Late 22:00-23:30, D 23:30-00:00, ", 0},
+	})
+}
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"strings"
 	"time"
@@ -11,16 +13,19 @@ const (
 )
 
 type Task struct {
+	ID          string    `json:"ID"`
 	Description string    `json:"Description"`
 	StartTime   time.Time `json:"Start"`
 	EndTime     time.Time `json:"End"`
 	Tag         string    `json:"Tag"`
+	Fixed       bool      `json:"Fixed"` // Whether the task can't be moved to make room for another
 }
 
 // NewTask returns a new Task object with the given description,
 // start time, and end time. If end <= start, an empty task is returned.
 func NewTask(desc string, start, end time.Time) Task {
 	t := Task{
+		ID:          NewTaskID(),
 		Description: desc,
 		StartTime:   start,
 		EndTime:     end,
@@ -41,6 +46,12 @@ func (t Task) WithTag(tag string) Task {
 	return t
 }
 
+// AsFixed returns the task marked as fixed or flexible.
+func (t Task) AsFixed(fixed bool) Task {
+	t.Fixed = fixed
+	return t
+}
+
 // Break returns a Task object to be used
 // as "free time" in a schedule.
 func Break(start, end time.Time) Task {
@@ -92,8 +103,9 @@ func (t Task) Conflicts(other Task) bool {
 // between the old task's time span and that of the new task.
 // it returns a Task with the updated times of oldTask. If
 // newTask's time is a subset of oldTask's, 2 Tasks
-// will be returned. It assumes the tasks have a conflict,
-// so oldTask may be incorrectly updated if there is none.
+// will be returned, and the second one is given a new ID.
+// It assumes the tasks have a conflict, so oldTask may be
+// incorrectly updated if there is none.
 func Resolve(oldTask, newTask Task) []*Task {
 	if !oldTask.Conflicts(newTask) {
 		return []*Task{&oldTask}
@@ -104,10 +116,12 @@ func Resolve(oldTask, newTask Task) []*Task {
 			return []*Task{&oldTask}
 		}
 		postTask := &Task{
+			ID:          NewTaskID(),
 			Description: oldTask.Description,
 			StartTime:   newTask.EndTime,
 			EndTime:     oldTask.EndTime,
 			Tag:         oldTask.Tag,
+			Fixed:       oldTask.Fixed,
 		}
 		oldTask.EndTime = newTask.StartTime
 		return []*Task{&oldTask, postTask}
@@ -124,6 +138,27 @@ func Resolve(oldTask, newTask Task) []*Task {
 
 // Helper functions
 
+// NewTaskID returns a random identifier for a task.
+func NewTaskID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// sameAs returns true if t and other have the same ID,
+// description, tag, time span, and fixedness.
+func (t Task) sameAs(other Task) bool {
+	return t.ID == other.ID &&
+		t.Description == other.Description &&
+		t.Tag == other.Tag &&
+		t.Fixed == other.Fixed &&
+		t.StartTime.Equal(other.StartTime) &&
+		t.EndTime.Equal(other.EndTime)
+}
+
 // Quantize rounds a task's start time and end time
 // to 5-minute increments.
 func (t *Task) Quantize() error {
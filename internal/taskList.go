@@ -10,17 +10,59 @@ import (
 type TaskList []*Task
 
 // GetTaskAtTime returns the task occupying the
-// time block that contains the given time.
+// time block that contains the given time. A task's
+// block includes its start time but not its end time,
+// so at a boundary the task that is starting is returned.
 // (nil, -1) is returned if there is no task at time t.
 func (tl TaskList) GetTaskAtTime(t time.Time) (*Task, int) {
 	for i := range tl {
-		if tl[i].StartTime.Compare(t) <= 0 && tl[i].EndTime.Compare(t) >= 0 {
+		if tl[i].StartTime.Compare(t) <= 0 && tl[i].EndTime.After(t) {
 			return tl[i], i
 		}
 	}
 	return nil, -1
 }
 
+// NextBoundary returns the first time after t at which a
+// task in the TaskList starts or ends. It returns false if
+// no task starts or ends after t. It assumes that the
+// TaskList is sorted.
+func (tl TaskList) NextBoundary(t time.Time) (time.Time, bool) {
+	for i := range tl {
+		if tl[i].StartTime.After(t) {
+			return tl[i].StartTime, true
+		}
+		if tl[i].EndTime.After(t) {
+			return tl[i].EndTime, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// GetTaskByID returns the task with the given ID
+// and its index in the TaskList.
+// (nil, -1) is returned if there is no such task.
+func (tl TaskList) GetTaskByID(id string) (*Task, int) {
+	for i := range tl {
+		if tl[i].ID == id {
+			return tl[i], i
+		}
+	}
+	return nil, -1
+}
+
+// WithoutBreaks returns the tasks in the TaskList
+// that aren't breaks. The tasks are not copied.
+func (tl TaskList) WithoutBreaks() TaskList {
+	tasks := TaskList{}
+	for _, t := range tl {
+		if !t.IsBreak() {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
 // IsConflict returns true if there is overlap between
 // the given task and any of the tasks currently in
 // the TaskList.
@@ -38,20 +80,30 @@ func (tl TaskList) IsConflict(t Task) bool {
 }
 
 // NewTaskList creates a new TaskList from the given tasks.
-// It returns nil and an error if there is a time conflict.
+// It returns nil and a TaskListError if any task is shorter
+// than 5 minutes or overlaps another.
 func NewTaskList(tasks ...Task) (TaskList, error) {
-	// add all tasks, sort, then check for conflicts
-
-	for _, t := range tasks {
-		if !t.IsValid() {
-			return nil, InvalidScheduleError{"Invalid Task was given."}
+	// check each task, then sort and check for conflicts
+	var taskErrs []TaskError
+	for i, t := range tasks {
+		switch {
+		case t.StartTime.IsZero() || t.EndTime.IsZero():
+			taskErrs = append(taskErrs, TaskError{i, t, InvalidTimeError{"Task is missing a start or end time."}})
+		case !t.IsValid():
+			taskErrs = append(taskErrs, TaskError{i, t, InvalidTimeError{"Task must be at least 5 minutes long."}})
 		}
 	}
+	if len(taskErrs) > 0 {
+		return nil, TaskListError{taskErrs}
+	}
 
 	tl := TaskList{}
 	var taskRef *Task
 	for t := 0; t < len(tasks); t++ {
 		taskRef = &tasks[t]
+		if taskRef.ID == "" {
+			taskRef.ID = NewTaskID()
+		}
 		err := taskRef.Quantize()
 		if err != nil {
 			return nil, fmt.Errorf("Error quantizing task: %v", err)
@@ -61,7 +113,7 @@ func NewTaskList(tasks ...Task) (TaskList, error) {
 
 	tl.sort()
 	if !tl.IsConsistent() {
-		return nil, InvalidScheduleError{"List of tasks contains a conflict."}
+		return nil, TaskListError{conflictErrors(tasks)}
 	}
 
 	// Add breaks as needed
@@ -79,6 +131,37 @@ func NewTaskList(tasks ...Task) (TaskList, error) {
 	return tl, nil
 }
 
+// conflictErrors returns an error for each pair of overlapping
+// tasks, given for whichever of the two comes later in tasks.
+func conflictErrors(tasks []Task) []TaskError {
+	order := make([]int, len(tasks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return tasks[order[a]].StartTime.Before(tasks[order[b]].StartTime)
+	})
+
+	var errs []TaskError
+	for a := range order {
+		for b := a + 1; b < len(order); b++ {
+			i, j := order[a], order[b]
+			if !tasks[j].StartTime.Before(tasks[i].EndTime) {
+				break
+			}
+			if j < i {
+				i, j = j, i
+			}
+			msg := fmt.Sprintf("Task overlaps task %d (%q).", i, tasks[i].Description)
+			errs = append(errs, TaskError{j, tasks[j], TimeConflictError{msg}})
+		}
+	}
+	sort.SliceStable(errs, func(a, b int) bool {
+		return errs[a].Index < errs[b].Index
+	})
+	return errs
+}
+
 // IsConsistent returns true if the TaskList has no overlapping
 // tasks, and false otherwise. It assumes that the TaskList is
 // sorted.
@@ -92,10 +175,21 @@ func (tl TaskList) IsConsistent() bool {
 	return true
 }
 
+// ResolveConflictsWith makes room for the new task using the given
+// strategy, and returns an updated copy of the task list. Tasks that
+// would be pushed past the given end no longer fit, and are left out
+// of the copy and returned separately. A zero end has no limit.
+func (tl TaskList) ResolveConflictsWith(newTask Task, cs ConflictStrategy, end time.Time) (TaskList, []Task, error) {
+	return cs.Resolve(tl, newTask, end)
+}
+
 // ResolveConflicts adjusts the start and end times of the tasks starting at
 // the given index to accomodate the new given task. It updates a copy of
-// the task list and returns the updated copy.
+// the task list and returns the updated copy. This is the Split strategy.
 func (tl TaskList) ResolveConflicts(newTask Task) (TaskList, error) {
+	if newTask.ID == "" {
+		newTask.ID = NewTaskID()
+	}
 
 	i := 0
 	for i < len(tl) {
@@ -123,6 +217,15 @@ func (tl TaskList) sort() {
 	sort.Slice(tl, func(i, j int) bool { return tl[i].EndTime.Compare(tl[j].StartTime) <= 0 })
 }
 
+// values returns a copy of the tasks in the TaskList.
+func (tl TaskList) values() []Task {
+	tasks := make([]Task, len(tl))
+	for i := range tl {
+		tasks[i] = *tl[i]
+	}
+	return tasks
+}
+
 func (tl TaskList) get(idx int) *Task {
 	return tl[idx]
 }
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"errors"
 	"testing"
 	"time"
 )
@@ -107,7 +108,7 @@ func TestIsConflict(t *testing.T) {
 }
 
 func TestResolveConflicts(t *testing.T) {
-	s, err := BuildFromFile("test_data/meals_w_breaks.csv")
+	s, err := BuildFromFile("test_data/meals_w_breaks.csv", ScheduleOptions{})
 	if err != nil {
 		t.Fatalf("Couldn't build schedule: %s", err.Error())
 	}
@@ -138,3 +139,64 @@ func TestResolveConflicts(t *testing.T) {
 			tl.get(3).Description, tl.get(4).Description, tl.get(5).Description, tl.get(6).Description, tl2.String(), tl.String())
 	}
 }
+
+func TestNextBoundary(t *testing.T) {
+	s, err := BuildFromFile("test_data/meals_w_breaks.csv", ScheduleOptions{})
+	if err != nil {
+		t.Fatalf("Couldn't build schedule: %s", err.Error())
+	}
+	tl := s.Tasks
+
+	next, ok := tl.NextBoundary(tl.get(0).StartTime.Add(-time.Hour))
+	if !ok || !next.Equal(tl.get(0).StartTime) {
+		t.Fatalf("Expected: %v, Got: %v", tl.get(0).StartTime, next)
+	}
+	next, ok = tl.NextBoundary(tl.get(0).StartTime)
+	if !ok || !next.Equal(tl.get(0).EndTime) {
+		t.Fatalf("Expected: %v, Got: %v", tl.get(0).EndTime, next)
+	}
+	next, ok = tl.NextBoundary(tl.get(2).StartTime.Add(time.Minute))
+	if !ok || !next.Equal(tl.get(2).EndTime) {
+		t.Fatalf("Expected: %v, Got: %v", tl.get(2).EndTime, next)
+	}
+	_, ok = tl.NextBoundary(tl.get(len(tl) - 1).EndTime)
+	if ok {
+		t.Fatalf("Expected no boundary after the last task ends")
+	}
+}
+
+func TestNewTaskListErrors(t *testing.T) {
+	now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+	_, err := NewTaskList(
+		NewTask("Lunch", now, now.Add(time.Hour)),
+		Task{Description: "Stretch", StartTime: now.Add(2 * time.Hour), EndTime: now.Add(2*time.Hour + 2*time.Minute)},
+		Task{Description: "No end", StartTime: now},
+	)
+	var listErr TaskListError
+	if !errors.As(err, &listErr) {
+		t.Fatalf("Expected TaskListError, got: %v", err)
+	}
+	if len(listErr.Tasks) != 2 || listErr.Tasks[0].Index != 1 || listErr.Tasks[1].Index != 2 {
+		t.Fatalf("Expected errors for tasks 1 and 2, got:\n%s", err.Error())
+	}
+	if !errors.As(err, &InvalidTimeError{}) {
+		t.Fatalf("Expected InvalidTimeError, got: %v", err)
+	}
+
+	_, err = NewTaskList(
+		NewTask("Lunch", now, now.Add(time.Hour)),
+		NewTask("Walk", now.Add(2*time.Hour), now.Add(3*time.Hour)),
+		NewTask("Call", now.Add(30*time.Minute), now.Add(150*time.Minute)),
+	)
+	if !errors.As(err, &listErr) {
+		t.Fatalf("Expected TaskListError, got: %v", err)
+	}
+	if len(listErr.Tasks) != 2 {
+		t.Fatalf("Expected 2 conflicts, got:\n%s", err.Error())
+	}
+	for _, e := range listErr.Tasks {
+		if e.Index != 2 || !errors.As(e, &TimeConflictError{}) {
+			t.Fatalf("Expected task 2 to conflict with tasks 0 and 1, got:\n%s", err.Error())
+		}
+	}
+}
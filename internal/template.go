@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Template is a named set of tasks that a day's schedule can be
+// built from. Each task repeats on the days its recurrence gives,
+// so one template can describe every day of a routine week.
+type Template struct {
+	Name  string         `json:"Name"`
+	Tasks []TemplateTask `json:"Tasks"`
+}
+
+// TemplateTask is a task in a Template. Its start is a time of
+// day, such as 09:00 or 9am, and its end is either a time of day
+// or a duration such as 45m. An end earlier than the start is on
+// the next date, as it is in a CSV file.
+type TemplateTask struct {
+	Description string     `json:"Description"`
+	Tag         string     `json:"Tag"`
+	Start       string     `json:"Start"`
+	End         string     `json:"End"`
+	Repeat      Recurrence `json:"Repeat"`
+	Fixed       bool       `json:"Fixed"`
+}
+
+// Recurrence gives the days on which a template task happens.
+// The zero Recurrence happens every day.
+type Recurrence struct {
+	Weekdays []time.Weekday // Days of the week it happens on; every day if empty
+	Interval int            // Days between occurrences, counted from From, if more than 1
+	From     time.Time      // Date of an occurrence, when Interval is more than 1
+}
+
+// ParseRecurrence parses a recurrence rule. The rule may be daily,
+// weekdays, weekends, a list of days of the week such as mon,wed,fri,
+// or every N days from a date, such as "every 3 days from 2024-03-01".
+// An empty rule is the same as daily.
+func ParseRecurrence(value string) (Recurrence, error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	switch v {
+	case "", "daily", "every day":
+		return Recurrence{}, nil
+	case "weekdays":
+		return Recurrence{Weekdays: []time.Weekday{
+			time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday,
+		}}, nil
+	case "weekends":
+		return Recurrence{Weekdays: []time.Weekday{time.Saturday, time.Sunday}}, nil
+	}
+
+	if strings.HasPrefix(v, "every ") {
+		fields := strings.Fields(v)
+		if len(fields) != 5 || (fields[2] != "days" && fields[2] != "day") || fields[3] != "from" {
+			return Recurrence{}, InvalidScheduleError{fmt.Sprintf("%q should be a rule such as \"every 3 days from 2024-03-01\".", value)}
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 1 {
+			return Recurrence{}, InvalidScheduleError{fmt.Sprintf("%q should repeat every 1 or more days.", value)}
+		}
+		from, err := time.Parse(time.DateOnly, fields[4])
+		if err != nil {
+			return Recurrence{}, InvalidScheduleError{fmt.Sprintf("%q should give a date formatted as %s.", value, time.DateOnly)}
+		}
+		if n == 1 {
+			return Recurrence{}, nil
+		}
+		return Recurrence{Interval: n, From: from}, nil
+	}
+
+	r := Recurrence{}
+	for _, name := range strings.Split(v, ",") {
+		wd, ok := parseWeekday(strings.TrimSpace(name))
+		if !ok {
+			return Recurrence{}, InvalidScheduleError{fmt.Sprintf("%q is not a day of the week.", strings.TrimSpace(name))}
+		}
+		if !containsWeekday(r.Weekdays, wd) {
+			r.Weekdays = append(r.Weekdays, wd)
+		}
+	}
+	sort.Slice(r.Weekdays, func(i, j int) bool {
+		return (r.Weekdays[i]+6)%7 < (r.Weekdays[j]+6)%7 // Weeks start on Monday
+	})
+	return r, nil
+}
+
+// parseWeekday parses the name of a day of the week, which
+// may be shortened to as few as its first three letters.
+func parseWeekday(name string) (time.Weekday, bool) {
+	if len(name) < 3 {
+		return 0, false
+	}
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		if strings.HasPrefix(strings.ToLower(wd.String()), name) {
+			return wd, true
+		}
+	}
+	return 0, false
+}
+
+// OccursOn returns true if the recurrence happens on the
+// calendar date of the given day.
+func (r Recurrence) OccursOn(day time.Time) bool {
+	if r.Interval > 1 {
+		days := int(civilDate(day).Sub(civilDate(r.From)).Hours() / 24)
+		return days >= 0 && days%r.Interval == 0
+	}
+	if len(r.Weekdays) > 0 {
+		return containsWeekday(r.Weekdays, day.Weekday())
+	}
+	return true
+}
+
+// String returns the recurrence as a rule that
+// ParseRecurrence can read.
+func (r Recurrence) String() string {
+	if r.Interval > 1 {
+		return fmt.Sprintf("every %d days from %s", r.Interval, r.From.Format(time.DateOnly))
+	}
+	days := make([]string, len(r.Weekdays))
+	for i, wd := range r.Weekdays {
+		days[i] = strings.ToLower(wd.String()[:3])
+	}
+	switch strings.Join(days, ",") {
+	case "":
+		return "daily"
+	case "mon,tue,wed,thu,fri":
+		return "weekdays"
+	case "sat,sun":
+		return "weekends"
+	}
+	return strings.Join(days, ",")
+}
+
+// MarshalText writes the recurrence as its rule, so
+// that templates can be written by hand.
+func (r Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText reads a recurrence rule; see ParseRecurrence.
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	rec, err := ParseRecurrence(string(text))
+	if err != nil {
+		return err
+	}
+	*r = rec
+	return nil
+}
+
+// Validate checks that every task in the template has a
+// description and times that can be read.
+func (t Template) Validate() error {
+	if len(t.Tasks) == 0 {
+		return InvalidScheduleError{"Template has no tasks."}
+	}
+	day := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i, task := range t.Tasks {
+		_, err := task.on(day)
+		if err != nil {
+			return fmt.Errorf("Validate: task %d (%q): %w", i, task.Description, err)
+		}
+	}
+	return nil
+}
+
+// TasksOn returns the template's tasks that happen on the
+// day beginning at the given time, in the day's time zone.
+// As in ParseCSV, times before the day's start are on the
+// next date. Whether a task happens is decided by the date
+// of the day's start.
+func (t Template) TasksOn(day time.Time) ([]Task, error) {
+	tasks := []Task{}
+	for i, task := range t.Tasks {
+		if !task.Repeat.OccursOn(day) {
+			continue
+		}
+		instance, err := task.on(day)
+		if err != nil {
+			return nil, fmt.Errorf("TasksOn: task %d (%q): %w", i, task.Description, err)
+		}
+		tasks = append(tasks, instance)
+	}
+	return tasks, nil
+}
+
+// on returns the task on the day beginning at the given time.
+func (t TemplateTask) on(day time.Time) (Task, error) {
+	if strings.TrimSpace(t.Description) == "" {
+		return Task{}, InvalidScheduleError{"Task has no description."}
+	}
+	start, err := parseTimeOfDay(t.Start, day)
+	if err != nil {
+		return Task{}, err
+	}
+	if start.Before(day) {
+		start = start.AddDate(0, 0, 1)
+	}
+	end, err := parseEndTime(t.End, start)
+	if err != nil {
+		return Task{}, err
+	}
+
+	task := NewTask(t.Description, start, end).WithTag(t.Tag).AsFixed(t.Fixed)
+	if !task.IsValid() {
+		return Task{}, InvalidTimeError{"Task must be at least 5 minutes long."}
+	}
+	return task, nil
+}
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	sunday := time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		rule     string
+		expected string
+		days     string // Whether it occurs on each day from Sunday the 10th
+	}{
+		{"", "daily", "1111111"},
+		{"Daily", "daily", "1111111"},
+		{"weekdays", "weekdays", "0111110"},
+		{"weekends", "weekends", "1000001"},
+		{"fri, Monday,wed,mon", "mon,wed,fri", "0101010"},
+		{"tues,thurs", "tue,thu", "0010100"},
+		{"every 3 days from 2024-03-09", "every 3 days from 2024-03-09", "0010010"},
+		{"every 1 day from 2024-03-09", "daily", "1111111"},
+	}
+	for _, test := range tests {
+		r, err := ParseRecurrence(test.rule)
+		if err != nil {
+			t.Fatalf("%q: %s", test.rule, err.Error())
+		}
+		if r.String() != test.expected {
+			t.Fatalf("Expected %q to be read as %q, got %q", test.rule, test.expected, r.String())
+		}
+		days := ""
+		for i := 0; i < 7; i++ {
+			if r.OccursOn(sunday.AddDate(0, 0, i)) {
+				days += "1"
+			} else {
+				days += "0"
+			}
+		}
+		if days != test.days {
+			t.Fatalf("Expected %q to occur on days %s, got %s", test.rule, test.days, days)
+		}
+	}
+
+	for _, rule := range []string{"mo", "someday", "every 3 days", "every 0 days from 2024-03-09", "every 3 weeks from 2024-03-09"} {
+		_, err := ParseRecurrence(rule)
+		if err == nil {
+			t.Fatalf("Expected an error for %q", rule)
+		}
+	}
+}
+
+func TestTemplateTasksOn(t *testing.T) {
+	input := `{"Tasks": [
+		{"Description": "Standup", "Tag": "meeting", "Start": "09:00", "End": "15m", "Repeat": "weekdays"},
+		{"Description": "Gym", "Start": "6pm", "End": "7pm", "Repeat": "mon,wed,fri"},
+		{"Description": "Review", "Start": "13:00", "End": "14:00", "Repeat": "weekends"},
+		{"Description": "Water plants", "Start": "08:00", "End": "08:10", "Repeat": "every 3 days from 2024-03-10"},
+		{"Description": "Night shift", "Start": "23:00", "End": "01:00", "Repeat": "sat"}
+	]}`
+	var tmpl Template
+	err := json.Unmarshal([]byte(input), &tmpl)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	err = tmpl.Validate()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	tests := []struct {
+		day      time.Time
+		expected string
+	}{
+		{time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC), "[Review Water plants]"},
+		{time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC), "[Standup Gym]"},
+		{time.Date(2024, time.March, 13, 0, 0, 0, 0, time.UTC), "[Standup Gym Water plants]"},
+		{time.Date(2024, time.March, 14, 0, 0, 0, 0, time.UTC), "[Standup]"},
+	}
+	for _, test := range tests {
+		tasks, err := tmpl.TasksOn(test.day)
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+		var desc []string
+		for _, task := range tasks {
+			desc = append(desc, task.Description)
+		}
+		if fmt.Sprint(desc) != test.expected {
+			t.Fatalf("Expected %s on %s, got %v", test.expected, test.day.Format(time.DateOnly), desc)
+		}
+	}
+
+	// Times before the start of the day are on the next date
+	saturday := time.Date(2024, time.March, 16, 4, 0, 0, 0, time.UTC)
+	tasks, err := tmpl.TasksOn(saturday)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	night := tasks[len(tasks)-1]
+	if night.StartTime.Format(time.DateTime) != "2024-03-16 23:00:00" || night.EndTime.Format(time.DateTime) != "2024-03-17 01:00:00" {
+		t.Fatalf("Expected the night shift to run past midnight, got %v to %v", night.StartTime, night.EndTime)
+	}
+
+	payload, err := json.Marshal(tmpl)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	var roundTrip Template
+	err = json.Unmarshal(payload, &roundTrip)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if fmt.Sprint(roundTrip) != fmt.Sprint(tmpl) {
+		t.Fatalf("Expected %v after a round trip, got %v", tmpl, roundTrip)
+	}
+
+	invalid := []Template{
+		{},
+		{Tasks: []TemplateTask{{Description: "", Start: "09:00", End: "10:00"}}},
+		{Tasks: []TemplateTask{{Description: "Task", Start: "9", End: "10:00"}}},
+		{Tasks: []TemplateTask{{Description: "Task", Start: "09:00", End: "soon"}}},
+		{Tasks: []TemplateTask{{Description: "Task", Start: "09:00", End: "2m"}}},
+	}
+	for _, tmpl := range invalid {
+		if tmpl.Validate() == nil {
+			t.Fatalf("Expected an error for %v", tmpl)
+		}
+	}
+}
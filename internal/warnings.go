@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// WarningPolicy decides how long before a task ends the user
+// is warned that it is ending. Tags listed in ByTag use their
+// own lead times instead of the default ones.
+type WarningPolicy struct {
+	Default []time.Duration
+	ByTag   map[string][]time.Duration
+}
+
+// Warning is a heads-up that a task will end soon.
+type Warning struct {
+	Task Task
+	Next *Task         // The next task that isn't a break, or nil if there is none
+	Lead time.Duration // How long before the end of Task the warning is due
+	At   time.Time     // When the warning is due
+}
+
+// LeadTimes returns the lead times used for tasks with the given tag.
+func (p WarningPolicy) LeadTimes(tag string) []time.Duration {
+	if leads, ok := p.ByTag[tag]; ok {
+		return leads
+	}
+	return p.Default
+}
+
+// WarningsBetween returns the warnings for the tasks in tl that
+// are due after from and no later than to, in the order they are
+// due.
+func (p WarningPolicy) WarningsBetween(tl TaskList, from, to time.Time) []Warning {
+	warnings := p.warningsAfter(tl, from)
+	for i := range warnings {
+		if warnings[i].At.After(to) {
+			return warnings[:i]
+		}
+	}
+	return warnings
+}
+
+// NextWarning returns the first warning for the tasks in tl
+// that is due after t. It returns false if there is none.
+func (p WarningPolicy) NextWarning(tl TaskList, t time.Time) (Warning, bool) {
+	warnings := p.warningsAfter(tl, t)
+	if len(warnings) == 0 {
+		return Warning{}, false
+	}
+	return warnings[0], true
+}
+
+// warningsAfter returns the warnings for the tasks in tl that
+// are due after t, in the order they are due. A warning is only
+// given while its task is running, so lead times longer than a
+// task are ignored. Breaks get no warnings of their own.
+func (p WarningPolicy) warningsAfter(tl TaskList, t time.Time) []Warning {
+	var warnings []Warning
+	var next *Task
+	for i := len(tl) - 1; i >= 0; i-- {
+		task := tl[i]
+		if task.IsBreak() {
+			continue
+		}
+		for _, lead := range p.LeadTimes(task.Tag) {
+			at := task.EndTime.Add(-lead)
+			if lead <= 0 || at.Before(task.StartTime) || !at.After(t) {
+				continue
+			}
+			w := Warning{
+				Task: *task,
+				Lead: lead,
+				At:   at,
+			}
+			if next != nil {
+				n := *next
+				w.Next = &n
+			}
+			warnings = append(warnings, w)
+		}
+		next = task
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].At.Before(warnings[j].At) })
+
+	return warnings
+}
+
+// ParseLeadTimes parses a comma-separated list of durations,
+// such as "10m,2m", into lead times.
+func ParseLeadTimes(s string) ([]time.Duration, error) {
+	var leads []time.Duration
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		lead, err := time.ParseDuration(field)
+		if err != nil {
+			return nil, err
+		}
+		if lead <= 0 {
+			return nil, InvalidTimeError{"Lead times must be positive."}
+		}
+		leads = append(leads, lead)
+	}
+	return leads, nil
+}
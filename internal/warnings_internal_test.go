@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWarnings(t *testing.T) {
+	c := NewFakeClock(time.Date(2024, time.March, 10, 9, 0, 0, 0, time.Local))
+	sched, err := BuildFromFile("./test_data/meals_w_breaks.csv", ScheduleOptions{Clock: c})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	p := WarningPolicy{
+		Default: []time.Duration{10 * time.Minute, 2 * time.Minute},
+		ByTag: map[string][]time.Duration{
+			"break": {15 * time.Minute},
+		},
+	}
+
+	// Breakfast ends at 09:15, so both default warnings fall within it
+	w, ok := p.NextWarning(sched.Tasks, c.Now())
+	if !ok || w.Task.Description != "Eat Breakfast" || w.Lead != 10*time.Minute {
+		t.Fatalf("Expected 10 minute warning for Eat Breakfast, got: %+v", w)
+	}
+	if w.At.Format(time.TimeOnly) != "09:05:00" {
+		t.Fatalf("Expected warning at 09:05:00, got %s", w.At.Format(time.TimeOnly))
+	}
+	if w.Next == nil || w.Next.Description != "Eat Lunch" {
+		t.Fatalf("Expected the next task to be Eat Lunch, not the break before it, got: %v", w.Next)
+	}
+
+	warnings := p.WarningsBetween(sched.Tasks, c.Now(), c.Now().Add(4*time.Hour))
+	// Breaks get no warnings, even with lead times for their tag
+	expected := []string{"09:05:00", "09:13:00", "12:35:00", "12:43:00"}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %d: %+v", len(expected), len(warnings), warnings)
+	}
+	for i := range expected {
+		if warnings[i].At.Format(time.TimeOnly) != expected[i] {
+			t.Fatalf("Expected warning %d at %s, got %s", i, expected[i], warnings[i].At.Format(time.TimeOnly))
+		}
+	}
+	if warnings[2].Task.Description != "Eat Lunch" || warnings[2].Next.Description != "Eat Dinner" {
+		t.Fatalf("Expected warning for Eat Lunch before Eat Dinner, got: %+v", warnings[2])
+	}
+
+	// A warning due exactly at from has already been given
+	warnings = p.WarningsBetween(sched.Tasks, warnings[0].At, warnings[0].At)
+	if len(warnings) != 0 {
+		t.Fatalf("Expected no warnings, got: %+v", warnings)
+	}
+}
+
+func TestParseLeadTimes(t *testing.T) {
+	leads, err := ParseLeadTimes("10m, 2m")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if len(leads) != 2 || leads[0] != 10*time.Minute || leads[1] != 2*time.Minute {
+		t.Fatalf("Expected [10m 2m], got %v", leads)
+	}
+	_, err = ParseLeadTimes("ten minutes")
+	if err == nil {
+		t.Fatalf("Expected error for an invalid duration")
+	}
+	_, err = ParseLeadTimes("-5m")
+	if err == nil {
+		t.Fatalf("Expected error for a negative duration")
+	}
+}